@@ -0,0 +1,278 @@
+package spankeys
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"github.com/golang/protobuf/proto"
+)
+
+// Limits of a commit
+// https://cloud.google.com/spanner/quotas#limits_for_creating_reading_updating_and_deleting_data
+const (
+	MaxMutationsPerCommit = 20000
+	MaxCommitSize         = 100 * 1000 * 1000
+	MaxCellSize           = 10 * 1024 * 1024
+)
+
+type MutationKind int
+
+const (
+	MutationInsert MutationKind = iota
+	MutationUpdate
+	MutationInsertOrUpdate
+	MutationReplace
+	MutationDelete
+)
+
+func (k MutationKind) String() string {
+	switch k {
+	case MutationInsert:
+		return "Insert"
+	case MutationUpdate:
+		return "Update"
+	case MutationInsertOrUpdate:
+		return "InsertOrUpdate"
+	case MutationReplace:
+		return "Replace"
+	case MutationDelete:
+		return "Delete"
+	}
+	return fmt.Sprintf("MutationKind(%d)", int(k))
+}
+
+// CalcBatchSize returns the max row count which can be written by one commit with the kind of mutations (see EstimateBatchSize).
+// Unlike CalcMutationBatchSize, MutationDelete is for deleting rows by keys, not by a key range.
+func CalcBatchSize(ctx context.Context, client *spanner.Client, tableName string, kind MutationKind) (int, error) {
+	cols, err := GetColumns(ctx, client, tableName)
+	if err != nil {
+		return 0, err
+	}
+	pkCols, err := GetPrimaryKeyColumns(ctx, client, tableName)
+	if err != nil {
+		return 0, err
+	}
+	var idxCnt int
+	if kind == MutationDelete {
+		idxCnt, err = CountIndexesWithChildren(ctx, client, tableName)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		idxes, err := GetSecondaryIndexes(ctx, client, tableName)
+		if err != nil {
+			return 0, err
+		}
+		idxCnt = len(idxes)
+	}
+	return EstimateBatchSize(kind, cols, pkCols, idxCnt), nil
+}
+
+// EstimateBatchSize returns the max row count which keeps a commit within both the mutation count limit and the commit size limit.
+// Inserts and updates count a mutation for each column and each index entry,
+// and row sizes are estimated by the max size of the column types.
+// STRING(MAX), BYTES(MAX), ARRAY and JSON have no bound smaller than a cell, so they are not estimated;
+// the writers of this package also flush a batch before its actual values exceed MaxCommitSize (see mutationBatch).
+func EstimateBatchSize(kind MutationKind, columns, pkColumns []*Column, indexCount int) int {
+	var mutationsPerRow, rowSize int64
+	if kind == MutationDelete {
+		mutationsPerRow = int64(1 + indexCount)
+		for _, col := range pkColumns {
+			rowSize += estimateColumnSize(columnType(col, columns))
+		}
+	} else {
+		mutationsPerRow = int64(len(columns) + indexCount)
+		for _, col := range columns {
+			rowSize += estimateColumnSize(parsedColumnType(col))
+		}
+	}
+
+	size := int64(math.MaxInt32)
+	if mutationsPerRow > 0 {
+		size = MaxMutationsPerCommit / mutationsPerRow
+	}
+	if rowSize > 0 && MaxCommitSize/rowSize < size {
+		size = MaxCommitSize / rowSize
+	}
+	if size < 1 {
+		size = 1
+	}
+	return int(size)
+}
+
+// columnType finds the type of the key column because GetPrimaryKeyColumns does not set it.
+func columnType(col *Column, columns []*Column) *SpannerType {
+	if t := parsedColumnType(col); t != nil {
+		return t
+	}
+	for _, c := range columns {
+		if c.Name == col.Name {
+			return parsedColumnType(c)
+		}
+	}
+	return nil
+}
+
+// estimateColumnSize returns the max byte size of a value of the type, or 0 if it is only limited by the cell size.
+func estimateColumnSize(t *SpannerType) int64 {
+	if t == nil {
+		return 0
+	}
+	switch t.Code {
+	case TypeBool:
+		return 1
+	case TypeInt64, TypeFloat64:
+		return 8
+	case TypeDate:
+		return 4
+	case TypeTimestamp:
+		return 12
+	case TypeNumeric:
+		return 22
+	case TypeString, TypeBytes:
+		if t.IsMax {
+			return 0
+		}
+		n := t.Length
+		// STRING(n) is the length in characters, and a character is up to 4 bytes in UTF-8
+		if t.Code == TypeString {
+			n *= 4
+		}
+		if n > MaxCellSize {
+			return MaxCellSize
+		}
+		return n
+	}
+	return 0
+}
+
+// mutationBatch collects the mutations of a commit, limited by the row count and the byte size of the values.
+// The byte size is a backstop for the columns which EstimateBatchSize cannot estimate.
+type mutationBatch struct {
+	maxRows   int
+	mutations []*spanner.Mutation
+	size      int64
+	// mutationCount counts a mutation per column of each row, as Cloud Spanner does.
+	mutationCount int64
+}
+
+// fits reports whether a row of the size can be added without exceeding the limits.
+// An empty batch always fits, so that a large row is committed alone.
+func (b *mutationBatch) fits(size int64) bool {
+	if len(b.mutations) == 0 {
+		return true
+	}
+	return len(b.mutations) < b.maxRows && b.size+size <= MaxCommitSize
+}
+
+func (b *mutationBatch) add(m *spanner.Mutation, size int64, columns int) {
+	b.mutations = append(b.mutations, m)
+	b.size += size
+	b.mutationCount += int64(columns)
+}
+
+func (b *mutationBatch) full() bool {
+	return len(b.mutations) >= b.maxRows || b.size >= MaxCommitSize
+}
+
+func (b *mutationBatch) reset() {
+	b.mutations = nil
+	b.size = 0
+	b.mutationCount = 0
+}
+
+// valuesSize returns the byte size of the values of a mutation.
+func valuesSize(values []interface{}) int64 {
+	var size int64
+	for _, v := range values {
+		size += valueSize(v)
+	}
+	return size
+}
+
+func valueSize(v interface{}) int64 {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case spanner.NullString:
+		return int64(len(v.StringVal))
+	case spanner.GenericColumnValue:
+		return int64(proto.Size(v.Value))
+	case spanner.NullJSON:
+		if !v.Valid {
+			return 0
+		}
+		data, _ := json.Marshal(v.Value)
+		return int64(len(data))
+	case big.Rat, spanner.NullNumeric:
+		return 22
+	case bool, spanner.NullBool:
+		return 1
+	case civil.Date, spanner.NullDate:
+		return 4
+	case time.Time, spanner.NullTime:
+		return 12
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		var size int64
+		for i := 0; i < rv.Len(); i++ {
+			size += valueSize(rv.Index(i).Interface())
+		}
+		return size
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return 0
+		}
+		return valueSize(rv.Elem().Interface())
+	case reflect.String:
+		return int64(rv.Len())
+	}
+	return 8
+}
+
+// CalcBatchSize returns the same batch size as the function CalcBatchSize, calculated from the schema instead of a database.
+func (s *Schema) CalcBatchSize(tableName string, kind MutationKind) (int, error) {
+	t := s.Table(tableName)
+	if t == nil {
+		return 0, fmt.Errorf("table %s not found", tableName)
+	}
+	idxCnt := s.countIndexes(tableName)
+	if kind == MutationDelete {
+		idxCnt = s.countIndexesWithChildren(tableName)
+	}
+	return EstimateBatchSize(kind, t.Columns, t.PrimaryKey, idxCnt), nil
+}
+
+func (s *Schema) countIndexes(tableName string) int {
+	cnt := 0
+	for _, idx := range s.Indexes {
+		if idx.Table == tableName {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// countIndexesWithChildren is the same as CountIndexesWithChildren by the schema.
+func (s *Schema) countIndexesWithChildren(tableName string) int {
+	cnt := s.countIndexes(tableName)
+	for _, t := range s.Tables {
+		if t.Interleave != nil && t.Interleave.Table == tableName && t.Interleave.OnDelete == OnDeleteCascade {
+			cnt += s.countIndexesWithChildren(t.Name)
+		}
+	}
+	return cnt
+}
@@ -0,0 +1,50 @@
+package spankeys_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+)
+
+func TestEstimateBatchSize(t *testing.T) {
+	cols := []*spankeys.Column{
+		{Name: "ID", SpannerType: "STRING(36)"},
+		{Name: "Name", SpannerType: "STRING(255)"},
+		{Name: "Age", SpannerType: "INT64"},
+	}
+	pkCols := []*spankeys.Column{{Name: "ID"}}
+
+	// 3 columns + 1 index per row
+	assert.Equal(t, 5000, spankeys.EstimateBatchSize(spankeys.MutationInsert, cols, pkCols, 1))
+	assert.Equal(t, 5000, spankeys.EstimateBatchSize(spankeys.MutationUpdate, cols, pkCols, 1))
+	assert.Equal(t, 5000, spankeys.EstimateBatchSize(spankeys.MutationInsertOrUpdate, cols, pkCols, 1))
+	// 1 row + 1 index per row
+	assert.Equal(t, 10000, spankeys.EstimateBatchSize(spankeys.MutationDelete, cols, pkCols, 1))
+	assert.Equal(t, 20000, spankeys.EstimateBatchSize(spankeys.MutationDelete, cols, pkCols, 0))
+
+	// bounded values are limited by the commit size
+	largeStrCols := []*spankeys.Column{
+		{Name: "ID", SpannerType: "STRING(36)"},
+		{Name: "Body", SpannerType: "STRING(1000000)"},
+	}
+	assert.Equal(t, 24, spankeys.EstimateBatchSize(spankeys.MutationInsertOrUpdate, largeStrCols, pkCols, 0))
+	// the key of a delete is estimated by the type in the columns
+	largeKeyCols := []*spankeys.Column{
+		{Name: "ID", SpannerType: "BYTES(10000)"},
+	}
+	assert.Equal(t, 10000, spankeys.EstimateBatchSize(spankeys.MutationDelete, largeKeyCols, pkCols, 0))
+
+	// MAX columns are limited by the actual values when writing, not by the max size of a cell
+	largeCols := []*spankeys.Column{
+		{Name: "ID", SpannerType: "INT64"},
+		{Name: "Title", SpannerType: "STRING(MAX)"},
+		{Name: "Body", SpannerType: "BYTES(MAX)"},
+		{Name: "Tags", SpannerType: "ARRAY<STRING(MAX)>"},
+		{Name: "Attrs", SpannerType: "JSON"},
+	}
+	assert.Equal(t, 4000, spankeys.EstimateBatchSize(spankeys.MutationInsert, largeCols, pkCols, 0))
+	assert.Equal(t, 3333, spankeys.EstimateBatchSize(spankeys.MutationInsertOrUpdate, largeCols, pkCols, 1))
+	assert.Equal(t, 20000, spankeys.EstimateBatchSize(spankeys.MutationDelete, largeCols, pkCols, 0))
+}
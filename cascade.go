@@ -0,0 +1,119 @@
+package spankeys
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+)
+
+// cascadeCost estimates mutations of deleting a row with its descendant rows of ON DELETE CASCADE.
+type cascadeCost struct {
+	indexCount  int
+	descendants []*cascadeDescendant
+
+	// rowMutations is the mutation count of the row itself;
+	// it is zero when deleting by a key range and one when deleting by keys.
+	rowMutations int64
+}
+
+type cascadeDescendant struct {
+	table      string
+	indexCount int
+}
+
+func newCascadeCost(ctx context.Context, client *spanner.Client, tableName string) (*cascadeCost, error) {
+	idxes, err := GetSecondaryIndexes(ctx, client, tableName)
+	if err != nil {
+		return nil, err
+	}
+	c := &cascadeCost{indexCount: len(idxes)}
+	if err := c.addDescendants(ctx, client, tableName); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *cascadeCost) addDescendants(ctx context.Context, client *spanner.Client, tableName string) error {
+	children, err := GetInterleaveChildren(ctx, client, tableName)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if child.OnDelete != OnDeleteCascade {
+			continue
+		}
+		idxes, err := GetSecondaryIndexes(ctx, client, child.Table)
+		if err != nil {
+			return err
+		}
+		c.descendants = append(c.descendants, &cascadeDescendant{table: child.Table, indexCount: len(idxes)})
+		if err := c.addDescendants(ctx, client, child.Table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selects returns expressions which count descendant rows of each row.
+// Primary key of an interleaved table starts with the key columns of the parent, so descendants can be joined by them.
+func (c *cascadeCost) selects(pkColumns []*Column) []string {
+	var exprs []string
+	for i, d := range c.descendants {
+		var conds []string
+		for _, col := range pkColumns {
+			conds = append(conds, fmt.Sprintf("d.`%s` = t.`%s`", col.Name, col.Name))
+		}
+		exprs = append(exprs, fmt.Sprintf("(SELECT COUNT(*) FROM `%s` AS d WHERE %s) AS spankeys_descendants%d", d.table, strings.Join(conds, " AND "), i))
+	}
+	return exprs
+}
+
+// rowCost returns the mutation count and the descendant row count of deleting the row.
+// As CalcMutationBatchSize, each index entry of the row and the descendant rows is counted as a mutation.
+func (c *cascadeCost) rowCost(r *spanner.Row) (int64, int64, error) {
+	mutations := c.rowMutations + int64(c.indexCount)
+	var descendants int64
+	for i, d := range c.descendants {
+		var cnt int64
+		if err := r.ColumnByName(fmt.Sprintf("spankeys_descendants%d", i), &cnt); err != nil {
+			return 0, 0, err
+		}
+		descendants += cnt
+		mutations += cnt * int64(d.indexCount)
+	}
+	return mutations, descendants, nil
+}
+
+// NewCascadeKeyRangeIterator returns a KeyRangeIterator for deleting a table with interleaved children of ON DELETE CASCADE.
+// Each key range is closed before its mutations, including the cascaded descendant rows, exceed the limit of a commit.
+// A row whose own mutations exceed the limit is yielded as a single key range.
+func NewCascadeKeyRangeIterator(ctx context.Context, client *spanner.Client, tableName string, pkColumns []*Column, opts *KeyRangeOptions) (*KeyRangeIterator, error) {
+	cost, err := newCascadeCost(ctx, client, tableName)
+	if err != nil {
+		return nil, err
+	}
+	o := KeyRangeOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.MutationBatchSize < 1 {
+		o.MutationBatchSize = math.MaxInt32
+	}
+	it := NewKeyRangeIterator(ctx, client, tableName, pkColumns, &o)
+	it.cascade = cost
+	// deleting a key range itself is 1 mutation
+	it.builder.maxMutations = MaxMutationsPerCommit - 1
+	return it, nil
+}
+
+// PartitionsCascadeKeyRanges partitions all rows of the table as NewCascadeKeyRangeIterator.
+func PartitionsCascadeKeyRanges(ctx context.Context, client *spanner.Client, tableName string, pkColumns []*Column, pageSize int) ([]*CountableKeyRange, error) {
+	it, err := NewCascadeKeyRangeIterator(ctx, client, tableName, pkColumns, &KeyRangeOptions{PageSize: pageSize})
+	if err != nil {
+		return nil, err
+	}
+	return collectKeyRanges(it)
+}
@@ -0,0 +1,102 @@
+package spankeys_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+	"github.com/castaneai/spankeys/testutils"
+)
+
+func TestPartitionsCascadeKeyRanges(t *testing.T) {
+	parentTableName := "CascadeTestParent"
+	childTableName := "CascadeTestChild"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ParentID STRING(36) NOT NULL,
+    Name STRING(255) NOT NULL,
+) PRIMARY KEY (ParentID)
+`, parentTableName), fmt.Sprintf(`
+CREATE INDEX %s_Name ON %s(Name)
+`, parentTableName, parentTableName), fmt.Sprintf(`
+CREATE TABLE %s (
+    ParentID STRING(36) NOT NULL,
+    ChildID STRING(36) NOT NULL,
+    Name STRING(255) NOT NULL,
+) PRIMARY KEY (ParentID, ChildID), INTERLEAVE IN PARENT %s ON DELETE CASCADE
+`, childTableName, parentTableName), fmt.Sprintf(`
+CREATE INDEX %s_Name ON %s(Name)
+`, childTableName, childTableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 100 parents which have 200 children each
+	for i := 0; i < 10; i++ {
+		if _, err := c.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+			var ms []*spanner.Mutation
+			for i := 0; i < 10; i++ {
+				parentID := uuid.Must(uuid.NewRandom()).String()
+				ms = append(ms, spanner.Insert(parentTableName, []string{"ParentID", "Name"}, []interface{}{parentID, parentID}))
+				for j := 0; j < 200; j++ {
+					childID := uuid.Must(uuid.NewRandom()).String()
+					ms = append(ms, spanner.Insert(childTableName, []string{"ParentID", "ChildID", "Name"}, []interface{}{parentID, childID, childID}))
+				}
+			}
+			return tx.BufferWrite(ms)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkCols, err := spankeys.GetPrimaryKeyColumns(ctx, c, parentTableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// CalcMutationBatchSize assumes a child per parent, so the whole table would be a single key range
+	mutationBatchSize, err := spankeys.CalcMutationBatchSize(ctx, c, parentTableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 9999, mutationBatchSize)
+
+	keysets, err := spankeys.PartitionsCascadeKeyRanges(ctx, c, parentTableName, pkCols, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a parent costs 1 + 200 mutations, so 99 parents per key range
+	assert.Equal(t, 2, len(keysets))
+	var rows, descendants int64
+	for _, ks := range keysets {
+		assert.True(t, ks.MutationCount < spankeys.MaxMutationsPerCommit)
+		rows += ks.RowCount
+		descendants += ks.DescendantRowCount
+	}
+	assert.Equal(t, int64(100), rows)
+	assert.Equal(t, int64(100*200), descendants)
+
+	for _, ks := range keysets {
+		if _, err := c.Apply(ctx, []*spanner.Mutation{spanner.Delete(parentTableName, ks)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	{
+		cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s", childTableName), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, int64(0), cnt)
+	}
+}
@@ -0,0 +1,288 @@
+package spankeys
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+)
+
+// Checkpoint is the progress of a job over key ranges.
+type Checkpoint struct {
+	// LastKey is the last key of the key ranges completed in order from the first one.
+	// A resumed job scans after it.
+	LastKey spanner.Key
+
+	// Completed are the key ranges completed after LastKey.
+	Completed []*CountableKeyRange
+
+	// ReadTimestamp is the timestamp at which a job reads all rows (e.g. CopyTable), so that a resumed job reads the same snapshot.
+	ReadTimestamp time.Time
+}
+
+type jsonCheckpoint struct {
+	LastKey       []*jsonKeyPart       `json:"lastKey,omitempty"`
+	Completed     []*CountableKeyRange `json:"completed"`
+	ReadTimestamp *time.Time           `json:"readTimestamp,omitempty"`
+}
+
+func (c Checkpoint) MarshalJSON() ([]byte, error) {
+	jc := &jsonCheckpoint{Completed: c.Completed}
+	if !c.ReadTimestamp.IsZero() {
+		jc.ReadTimestamp = &c.ReadTimestamp
+	}
+	if c.LastKey != nil {
+		lastKey, err := encodeJSONKey(c.LastKey)
+		if err != nil {
+			return nil, err
+		}
+		jc.LastKey = lastKey
+	}
+	if jc.Completed == nil {
+		jc.Completed = []*CountableKeyRange{}
+	}
+	return json.Marshal(jc)
+}
+
+func (c *Checkpoint) UnmarshalJSON(data []byte) error {
+	var jc jsonCheckpoint
+	if err := json.Unmarshal(data, &jc); err != nil {
+		return err
+	}
+	var lastKey spanner.Key
+	if jc.LastKey != nil {
+		key, err := decodeJSONKey(jc.LastKey)
+		if err != nil {
+			return err
+		}
+		lastKey = key
+	}
+	*c = Checkpoint{LastKey: lastKey, Completed: jc.Completed}
+	if jc.ReadTimestamp != nil {
+		c.ReadTimestamp = *jc.ReadTimestamp
+	}
+	return nil
+}
+
+// CheckpointStore persists a Checkpoint of a job.
+type CheckpointStore interface {
+	// Load returns the saved checkpoint, or nil if nothing has been saved.
+	Load(ctx context.Context) (*Checkpoint, error)
+	Save(ctx context.Context, cp *Checkpoint) error
+}
+
+// FileCheckpointStore saves a checkpoint to a local JSON file.
+type FileCheckpointStore struct {
+	Path string
+}
+
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{Path: path}
+}
+
+func (s *FileCheckpointStore) Load(ctx context.Context) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// Save writes the checkpoint to a temporary file and renames it, so that the file is never left half-written.
+func (s *FileCheckpointStore) Save(ctx context.Context, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.Path)
+}
+
+// SpannerCheckpointStore saves a checkpoint to a row of a Cloud Spanner table, which has the following schema.
+//
+//	CREATE TABLE SpankeysCheckpoints (
+//	    JobID STRING(MAX) NOT NULL,
+//	    Checkpoint STRING(MAX) NOT NULL,
+//	    UpdatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+//	) PRIMARY KEY (JobID)
+type SpannerCheckpointStore struct {
+	Client    *spanner.Client
+	TableName string
+	JobID     string
+}
+
+func NewSpannerCheckpointStore(client *spanner.Client, tableName, jobID string) *SpannerCheckpointStore {
+	return &SpannerCheckpointStore{Client: client, TableName: tableName, JobID: jobID}
+}
+
+func (s *SpannerCheckpointStore) Load(ctx context.Context) (*Checkpoint, error) {
+	row, err := s.Client.Single().ReadRow(ctx, s.TableName, spanner.Key{s.JobID}, []string{"Checkpoint"})
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var data string
+	if err := row.Column(0, &data); err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal([]byte(data), &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+func (s *SpannerCheckpointStore) Save(ctx context.Context, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.Apply(ctx, []*spanner.Mutation{
+		spanner.InsertOrUpdate(s.TableName, []string{"JobID", "Checkpoint", "UpdatedAt"}, []interface{}{s.JobID, string(data), spanner.CommitTimestamp}),
+	})
+	return err
+}
+
+// checkpointProgress tracks completed key ranges by their sequence number in the scan order.
+type checkpointProgress struct {
+	store CheckpointStore
+	cols  []*Column
+
+	mu      sync.Mutex
+	lastKey spanner.Key
+	// prev has completed key ranges of the previous run
+	prev []*CountableKeyRange
+	// done has completed key ranges after the lastKey
+	done    map[int]*CountableKeyRange
+	nextSeq int
+	// latest is the checkpoint of the current progress, numbered by version
+	latest  *Checkpoint
+	version int
+
+	// saveMu serializes saving, so that an older checkpoint never overwrites a newer one
+	saveMu       sync.Mutex
+	savedVersion int
+}
+
+func newCheckpointProgress(store CheckpointStore, cols []*Column, cp *Checkpoint) *checkpointProgress {
+	p := &checkpointProgress{store: store, cols: cols, done: make(map[int]*CountableKeyRange)}
+	if cp != nil {
+		p.lastKey = cp.LastKey
+		p.prev = cp.Completed
+	}
+	return p
+}
+
+// isCompleted reports whether the key range is contained in a completed key range of the previous run.
+func (p *checkpointProgress) isCompleted(r *CountableKeyRange) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.prev {
+		if compareBounds(startBound(c.KeyRange), startBound(r.KeyRange), p.cols) <= 0 &&
+			compareBounds(endBound(r.KeyRange), endBound(c.KeyRange), p.cols) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// complete records the completed key range, and returns the version of the checkpoint which contains it.
+func (p *checkpointProgress) complete(seq int, r *CountableKeyRange) int {
+	if p.store == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done[seq] = withoutKeys(r)
+	for {
+		dr, ok := p.done[p.nextSeq]
+		if !ok {
+			break
+		}
+		p.lastKey = dr.End
+		delete(p.done, p.nextSeq)
+		p.nextSeq++
+	}
+
+	// drops the key ranges of the previous run which are before lastKey
+	var prev []*CountableKeyRange
+	for _, c := range p.prev {
+		if compareBounds(endBound(c.KeyRange), keyBound{key: p.lastKey, after: true}, p.cols) > 0 {
+			prev = append(prev, c)
+		}
+	}
+	p.prev = prev
+
+	var seqs []int
+	for s := range p.done {
+		seqs = append(seqs, s)
+	}
+	sort.Ints(seqs)
+	cp := &Checkpoint{LastKey: p.lastKey}
+	for _, s := range seqs {
+		cp.Completed = append(cp.Completed, p.done[s])
+	}
+	cp.Completed = append(cp.Completed, p.prev...)
+	p.latest = cp
+	p.version++
+	return p.version
+}
+
+// save persists the checkpoint of the version or a newer one.
+// It saves the latest checkpoint, so the callers waiting for a slow save are done by a single save.
+func (p *checkpointProgress) save(ctx context.Context, version int) error {
+	if p.store == nil {
+		return nil
+	}
+	p.saveMu.Lock()
+	defer p.saveMu.Unlock()
+	if version <= p.savedVersion {
+		return nil
+	}
+	p.mu.Lock()
+	cp, latest := p.latest, p.version
+	p.mu.Unlock()
+	if err := p.store.Save(ctx, cp); err != nil {
+		return err
+	}
+	p.savedVersion = latest
+	return nil
+}
+
+// withoutKeys drops the collected keys, which are too large for a checkpoint.
+func withoutKeys(r *CountableKeyRange) *CountableKeyRange {
+	if r.Keys == nil {
+		return r
+	}
+	c := *r
+	c.Keys = nil
+	return &c
+}
@@ -0,0 +1,187 @@
+package spankeys_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+	"github.com/castaneai/spankeys/testutils"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "spankeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := spankeys.NewFileCheckpointStore(filepath.Join(dir, "checkpoint.json"))
+	cp, err := store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, cp)
+
+	saved := &spankeys.Checkpoint{
+		LastKey: spanner.Key{"b", int64(2)},
+		Completed: []*spankeys.CountableKeyRange{
+			{KeyRange: spanner.KeyRange{Start: spanner.Key{"d", int64(1)}, End: spanner.Key{"e", int64(1)}, Kind: spanner.ClosedClosed}, RowCount: 10},
+		},
+		ReadTimestamp: time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+	}
+	if err := store.Save(ctx, saved); err != nil {
+		t.Fatal(err)
+	}
+	cp, err = store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, saved, cp)
+}
+
+func TestRunKeyRangesWithCheckpoint(t *testing.T) {
+	tableName := "CheckpointTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+) PRIMARY KEY (ID)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ms []*spanner.Mutation
+	for i := 0; i < 1000; i++ {
+		ms = append(ms, spanner.Insert(tableName, []string{"ID"}, []interface{}{int64(i)}))
+	}
+	if _, err := c.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	pkCols, err := spankeys.GetPrimaryKeyColumns(ctx, c, tableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "spankeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := spankeys.NewFileCheckpointStore(filepath.Join(dir, "checkpoint.json"))
+
+	var mu sync.Mutex
+	processed := make(map[int64]int)
+	process := func(failAt int64) spankeys.RangeFunc {
+		return func(ctx context.Context, r *spankeys.CountableKeyRange) error {
+			start := r.Start[0].(int64)
+			if start == failAt {
+				return errors.New("interrupted")
+			}
+			mu.Lock()
+			processed[start]++
+			mu.Unlock()
+			return nil
+		}
+	}
+	newIterator := func() *spankeys.KeyRangeIterator {
+		return spankeys.NewKeyRangeIterator(ctx, c, tableName, pkCols, &spankeys.KeyRangeOptions{MutationBatchSize: 100})
+	}
+
+	// the first run fails at the 4th key range
+	report, err := spankeys.RunKeyRanges(ctx, newIterator(), process(300), &spankeys.RunOptions{Concurrency: 2, Checkpoint: store})
+	assert.Error(t, err)
+	assert.Equal(t, 9, len(report.Completed))
+
+	cp, err := store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, spanner.Key{int64(299)}, cp.LastKey)
+	assert.Equal(t, 6, len(cp.Completed))
+
+	// the second run processes only the failed key range
+	report, err = spankeys.RunKeyRanges(ctx, newIterator(), process(-1), &spankeys.RunOptions{Concurrency: 2, Checkpoint: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(report.Completed))
+	assert.Equal(t, 6, len(report.Skipped))
+	assert.Equal(t, int64(100), report.RowCount)
+	for i := int64(0); i < 1000; i += 100 {
+		assert.Equal(t, 1, processed[i], "key range starts with %d", i)
+	}
+
+	cp, err = store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, spanner.Key{int64(999)}, cp.LastKey)
+	assert.Equal(t, 0, len(cp.Completed))
+}
+
+type failingCheckpointStore struct{}
+
+func (s *failingCheckpointStore) Load(ctx context.Context) (*spankeys.Checkpoint, error) {
+	return nil, nil
+}
+
+func (s *failingCheckpointStore) Save(ctx context.Context, cp *spankeys.Checkpoint) error {
+	return errors.New("unavailable")
+}
+
+func TestRunKeyRangesCheckpointError(t *testing.T) {
+	tableName := "CheckpointErrorTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+) PRIMARY KEY (ID)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ms []*spanner.Mutation
+	for i := 0; i < 300; i++ {
+		ms = append(ms, spanner.Insert(tableName, []string{"ID"}, []interface{}{int64(i)}))
+	}
+	if _, err := c.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	pkCols, err := spankeys.GetPrimaryKeyColumns(ctx, c, tableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := spankeys.NewKeyRangeIterator(ctx, c, tableName, pkCols, &spankeys.KeyRangeOptions{MutationBatchSize: 100})
+	report, err := spankeys.RunKeyRanges(ctx, it, func(ctx context.Context, r *spankeys.CountableKeyRange) error {
+		return nil
+	}, &spankeys.RunOptions{Concurrency: 2, Checkpoint: &failingCheckpointStore{}})
+	assert.IsType(t, spankeys.CheckpointErrors{}, err)
+	assert.Equal(t, 0, len(report.Completed))
+	assert.Equal(t, int64(0), report.RowCount)
+	assert.Equal(t, 3, len(report.CheckpointErrors))
+}
@@ -0,0 +1,371 @@
+package spankeys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+)
+
+type CopyOptions struct {
+	// Concurrency is the number of key ranges copied at the same time (default: 1).
+	Concurrency int
+
+	// BatchSize is the max row count written by one commit (default: result of CalcBatchSize of the destination).
+	BatchSize int
+
+	// PageSize is the row count fetched by one query while partitioning (default: DefaultPageSize).
+	PageSize int
+
+	// ReadTimestamp is the timestamp at which all rows are read from the source (default: the current time of the source).
+	// It is saved in Checkpoint, and a resumed copy reads at the saved timestamp.
+	ReadTimestamp time.Time
+
+	// Checkpoint makes the copy resumable. See RunOptions.
+	Checkpoint CheckpointStore
+
+	// Throttle limits the rate of writing to the destination. See RunOptions.
+	Throttle *Throttle
+
+	// Observer receives the progress of the copy. See RunOptions.
+	Observer Observer
+}
+
+type CopyReport struct {
+	CopiedRanges   []*CountableKeyRange
+	CopiedRowCount int64
+	ReadTimestamp  time.Time
+
+	// TableRowCounts is the copied row count of each table.
+	TableRowCounts map[string]int64
+}
+
+// CopyTable reads all rows of the table in src range by range at one read timestamp,
+// and writes them to the same table in dst by InsertOrUpdate mutations.
+// The columns and the primary key of the table must be the same in src and dst.
+func CopyTable(ctx context.Context, src, dst *spanner.Client, tableName string, opts *CopyOptions) (*CopyReport, error) {
+	return copyTables(ctx, src, dst, []string{tableName}, opts)
+}
+
+// CopyInterleavedTables copies the table and all of its interleaved descendant tables from src to dst.
+// Each key range of the table is copied with the rows of the descendants in the range,
+// and the parent rows are always written before their child rows.
+func CopyInterleavedTables(ctx context.Context, src, dst *spanner.Client, tableName string, opts *CopyOptions) (*CopyReport, error) {
+	tables, err := GetTables(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := BuildInterleaveTree(tables)
+	if err != nil {
+		return nil, err
+	}
+	node := findTableNode(roots, tableName)
+	if node == nil {
+		return nil, fmt.Errorf("table %s not found in the source", tableName)
+	}
+	var names []string
+	for _, t := range node.Tables() {
+		names = append(names, t.Name)
+	}
+	return copyTables(ctx, src, dst, names, opts)
+}
+
+// CopyDatabase copies all tables from src to dst in parent-before-child order at one read timestamp.
+// Checkpoint is not supported because each root table is scanned separately.
+func CopyDatabase(ctx context.Context, src, dst *spanner.Client, opts *CopyOptions) (*CopyReport, error) {
+	o := CopyOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Checkpoint != nil {
+		return nil, errors.New("CopyDatabase does not support Checkpoint")
+	}
+	tables, err := GetTables(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := BuildInterleaveTree(tables)
+	if err != nil {
+		return nil, err
+	}
+	if o.ReadTimestamp.IsZero() {
+		ts, err := currentTimestamp(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		o.ReadTimestamp = ts
+	}
+
+	report := &CopyReport{TableRowCounts: map[string]int64{}, ReadTimestamp: o.ReadTimestamp}
+	for _, root := range roots {
+		var names []string
+		for _, t := range root.Tables() {
+			names = append(names, t.Name)
+		}
+		r, err := copyTables(ctx, src, dst, names, &o)
+		if r != nil {
+			report.CopiedRanges = append(report.CopiedRanges, r.CopiedRanges...)
+			report.CopiedRowCount += r.CopiedRowCount
+			for name, cnt := range r.TableRowCounts {
+				report.TableRowCounts[name] = cnt
+			}
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// copyTables copies the rows of tables[0] range by range.
+// The rest of tables are its descendants in parent-before-child order, and their rows are copied in the same key ranges.
+func copyTables(ctx context.Context, src, dst *spanner.Client, tables []string, opts *CopyOptions) (*CopyReport, error) {
+	if opts == nil {
+		opts = &CopyOptions{}
+	}
+	columns := make([][]string, len(tables))
+	batchSizes := make([]int, len(tables))
+	var pkCols []*Column
+	for i, table := range tables {
+		cols, pks, err := validateCopySchema(ctx, src, dst, table)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			pkCols = pks
+		}
+		columns[i] = columnNames(writableColumns(cols))
+		batchSizes[i] = opts.BatchSize
+		if batchSizes[i] < 1 {
+			bs, err := CalcBatchSize(ctx, dst, table, MutationInsertOrUpdate)
+			if err != nil {
+				return nil, err
+			}
+			batchSizes[i] = bs
+		}
+	}
+	ts, err := copyReadTimestamp(ctx, src, opts)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := opts.Checkpoint
+	if checkpoint != nil {
+		checkpoint = &readTimestampCheckpointStore{CheckpointStore: checkpoint, ts: ts}
+	}
+
+	var (
+		mu     sync.Mutex
+		copied = map[string]int64{}
+	)
+	it := NewKeyRangeIterator(ctx, src, tables[0], pkCols, &KeyRangeOptions{
+		MutationBatchSize: batchSizes[0],
+		PageSize:          opts.PageSize,
+		TimestampBound:    spanner.ReadTimestamp(ts),
+	})
+	run, err := RunKeyRanges(ctx, it, func(ctx context.Context, r *CountableKeyRange) error {
+		for i, table := range tables {
+			n, err := copyKeyRange(ctx, src, dst, table, columns[i], r.KeyRange, ts, batchSizes[i])
+			mu.Lock()
+			copied[table] += n
+			mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, &RunOptions{
+		Concurrency: opts.Concurrency,
+		Checkpoint:  checkpoint,
+		Throttle:    opts.Throttle,
+		Observer:    opts.Observer,
+	})
+	if run == nil {
+		return nil, err
+	}
+	report := &CopyReport{CopiedRanges: run.Completed, TableRowCounts: copied, ReadTimestamp: ts}
+	for _, cnt := range copied {
+		report.CopiedRowCount += cnt
+	}
+	return report, err
+}
+
+// copyReadTimestamp returns the read timestamp saved in the checkpoint, ReadTimestamp or the current timestamp,
+// so that a resumed copy never mixes two snapshots.
+func copyReadTimestamp(ctx context.Context, src *spanner.Client, opts *CopyOptions) (time.Time, error) {
+	if opts.Checkpoint != nil {
+		cp, err := opts.Checkpoint.Load(ctx)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if cp != nil {
+			switch {
+			case cp.ReadTimestamp.IsZero() && opts.ReadTimestamp.IsZero():
+				return time.Time{}, errors.New("the checkpoint has no read timestamp; set ReadTimestamp of the previous copy to resume it")
+			case cp.ReadTimestamp.IsZero():
+				return opts.ReadTimestamp, nil
+			case !opts.ReadTimestamp.IsZero() && !opts.ReadTimestamp.Equal(cp.ReadTimestamp):
+				return time.Time{}, fmt.Errorf("ReadTimestamp %s does not match %s in the checkpoint",
+					opts.ReadTimestamp.Format(time.RFC3339Nano), cp.ReadTimestamp.Format(time.RFC3339Nano))
+			}
+			return cp.ReadTimestamp, nil
+		}
+	}
+	if !opts.ReadTimestamp.IsZero() {
+		return opts.ReadTimestamp, nil
+	}
+	return currentTimestamp(ctx, src)
+}
+
+// readTimestampCheckpointStore saves the read timestamp of a copy with the checkpoint.
+type readTimestampCheckpointStore struct {
+	CheckpointStore
+	ts time.Time
+}
+
+func (s *readTimestampCheckpointStore) Save(ctx context.Context, cp *Checkpoint) error {
+	c := *cp
+	c.ReadTimestamp = s.ts
+	return s.CheckpointStore.Save(ctx, &c)
+}
+
+func findTableNode(nodes []*TableNode, name string) *TableNode {
+	for _, node := range nodes {
+		if node.Name == name {
+			return node
+		}
+		if found := findTableNode(node.Children, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// copyKeyRange copies the rows in the key range, committing every batchSize rows or before the values exceed MaxCommitSize.
+func copyKeyRange(ctx context.Context, src, dst *spanner.Client, tableName string, columns []string, kr spanner.KeyRange, ts time.Time, batchSize int) (int64, error) {
+	iter := src.Single().WithTimestampBound(spanner.ReadTimestamp(ts)).Read(ctx, tableName, kr, columns)
+	defer iter.Stop()
+
+	var copied int64
+	batch := &mutationBatch{maxRows: batchSize}
+	flush := func() error {
+		if err := applyMutations(ctx, dst, batch.mutations, batch.mutationCount); err != nil {
+			return err
+		}
+		copied += int64(len(batch.mutations))
+		batch.reset()
+		return nil
+	}
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return copied, err
+		}
+		values := make([]interface{}, row.Size())
+		for i := range values {
+			var v spanner.GenericColumnValue
+			if err := row.Column(i, &v); err != nil {
+				return copied, err
+			}
+			values[i] = v
+		}
+		size := valuesSize(values)
+		if !batch.fits(size) {
+			if err := flush(); err != nil {
+				return copied, err
+			}
+		}
+		batch.add(spanner.InsertOrUpdate(tableName, columns, values), size, len(columns))
+		if batch.full() {
+			if err := flush(); err != nil {
+				return copied, err
+			}
+		}
+	}
+	if len(batch.mutations) > 0 {
+		if err := flush(); err != nil {
+			return copied, err
+		}
+	}
+	return copied, nil
+}
+
+// validateCopySchema returns the columns and the primary key columns of the table
+// if they are the same in src and dst.
+func validateCopySchema(ctx context.Context, src, dst *spanner.Client, tableName string) ([]*Column, []*Column, error) {
+	srcCols, err := GetColumns(ctx, src, tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	dstCols, err := GetColumns(ctx, dst, tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(srcCols) < 1 {
+		return nil, nil, fmt.Errorf("table %s not found in the source", tableName)
+	}
+	if len(srcCols) != len(dstCols) {
+		return nil, nil, fmt.Errorf("table %s has %d columns in the source but %d columns in the destination", tableName, len(srcCols), len(dstCols))
+	}
+	for i := range srcCols {
+		if srcCols[i].Name != dstCols[i].Name || srcCols[i].SpannerType != dstCols[i].SpannerType {
+			return nil, nil, fmt.Errorf("column %s %s of table %s in the source does not match %s %s in the destination",
+				srcCols[i].Name, srcCols[i].SpannerType, tableName, dstCols[i].Name, dstCols[i].SpannerType)
+		}
+	}
+
+	srcPKs, err := GetPrimaryKeyColumns(ctx, src, tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	dstPKs, err := GetPrimaryKeyColumns(ctx, dst, tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(srcPKs) != len(dstPKs) {
+		return nil, nil, fmt.Errorf("primary key of table %s does not match between the source and the destination", tableName)
+	}
+	for i := range srcPKs {
+		if srcPKs[i].Name != dstPKs[i].Name || srcPKs[i].IsDesc() != dstPKs[i].IsDesc() {
+			return nil, nil, fmt.Errorf("primary key of table %s does not match between the source and the destination", tableName)
+		}
+	}
+	return srcCols, srcPKs, nil
+}
+
+// currentTimestamp returns the timestamp of a strong read.
+func currentTimestamp(ctx context.Context, client *spanner.Client) (time.Time, error) {
+	tx := client.Single()
+	defer tx.Close()
+	if err := tx.Query(ctx, spanner.NewStatement("SELECT 1")).Do(func(r *spanner.Row) error {
+		return nil
+	}); err != nil {
+		return time.Time{}, err
+	}
+	return tx.Timestamp()
+}
+
+func columnNames(cols []*Column) []string {
+	var names []string
+	for _, col := range cols {
+		names = append(names, col.Name)
+	}
+	return names
+}
+
+// writableColumns returns the columns except for the generated columns.
+func writableColumns(cols []*Column) []*Column {
+	var writable []*Column
+	for _, col := range cols {
+		if !col.IsGenerated() {
+			writable = append(writable, col)
+		}
+	}
+	return writable
+}
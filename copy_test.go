@@ -0,0 +1,207 @@
+package spankeys_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+	"github.com/castaneai/spankeys/testutils"
+)
+
+func TestCopyTable(t *testing.T) {
+	tableName := "CopyTableTest"
+	ddls := []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+    Name STRING(255),
+    Data BYTES(MAX),
+    CreatedAt TIMESTAMP NOT NULL,
+) PRIMARY KEY (ID)
+`, tableName)}
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, ddls); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutils.PrepareAnotherDatabase(ctx, "dst", ddls); err != nil {
+		t.Fatal(err)
+	}
+	src, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := testutils.NewAnotherSpannerClient(ctx, "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ms []*spanner.Mutation
+	for i := 0; i < 1000; i++ {
+		name := spanner.NullString{StringVal: fmt.Sprintf("name%d", i), Valid: i%10 != 0}
+		ms = append(ms, spanner.Insert(tableName, []string{"ID", "Name", "Data", "CreatedAt"}, []interface{}{int64(i), name, []byte{byte(i)}, time.Now()}))
+	}
+	if _, err := src.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := spankeys.CopyTable(ctx, src, dst, tableName, &spankeys.CopyOptions{Concurrency: 2, BatchSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 10, len(report.CopiedRanges))
+	assert.Equal(t, int64(1000), report.CopiedRowCount)
+	assert.False(t, report.ReadTimestamp.IsZero())
+
+	cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s", tableName), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(1000), cnt)
+	cnt, err = testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s where Name is null", tableName), dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(100), cnt)
+
+	// the read timestamp is saved in the checkpoint, and a resumed copy reads at it
+	dir, err := ioutil.TempDir("", "spankeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := spankeys.NewFileCheckpointStore(filepath.Join(dir, "checkpoint.json"))
+	report, err = spankeys.CopyTable(ctx, src, dst, tableName, &spankeys.CopyOptions{BatchSize: 100, Checkpoint: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, report.ReadTimestamp.Equal(cp.ReadTimestamp))
+
+	resumed, err := spankeys.CopyTable(ctx, src, dst, tableName, &spankeys.CopyOptions{BatchSize: 100, Checkpoint: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, report.ReadTimestamp.Equal(resumed.ReadTimestamp))
+	_, err = spankeys.CopyTable(ctx, src, dst, tableName, &spankeys.CopyOptions{
+		BatchSize:     100,
+		Checkpoint:    store,
+		ReadTimestamp: report.ReadTimestamp.Add(-time.Second),
+	})
+	assert.Error(t, err)
+
+	// a checkpoint without the read timestamp cannot be resumed without ReadTimestamp
+	if err := store.Save(ctx, &spankeys.Checkpoint{LastKey: spanner.Key{int64(99)}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = spankeys.CopyTable(ctx, src, dst, tableName, &spankeys.CopyOptions{BatchSize: 100, Checkpoint: store})
+	assert.Error(t, err)
+}
+
+func TestCopyTableSchemaMismatch(t *testing.T) {
+	tableName := "CopyTableMismatchTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+    Name STRING(255),
+) PRIMARY KEY (ID)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutils.PrepareAnotherDatabase(ctx, "dst", []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+    Name STRING(MAX),
+) PRIMARY KEY (ID)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+	src, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := testutils.NewAnotherSpannerClient(ctx, "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = spankeys.CopyTable(ctx, src, dst, tableName, nil)
+	assert.Error(t, err)
+}
+
+func TestCopyDatabase(t *testing.T) {
+	ddls := []string{`
+CREATE TABLE CopyParent (
+    ParentID INT64 NOT NULL,
+) PRIMARY KEY (ParentID)
+`, `
+CREATE TABLE CopyChild (
+    ParentID INT64 NOT NULL,
+    ChildID INT64 NOT NULL,
+) PRIMARY KEY (ParentID, ChildID),
+INTERLEAVE IN PARENT CopyParent ON DELETE CASCADE
+`, `
+CREATE TABLE CopyGrandChild (
+    ParentID INT64 NOT NULL,
+    ChildID INT64 NOT NULL,
+    GrandChildID INT64 NOT NULL,
+) PRIMARY KEY (ParentID, ChildID, GrandChildID),
+INTERLEAVE IN PARENT CopyChild ON DELETE CASCADE
+`}
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, ddls); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutils.PrepareAnotherDatabase(ctx, "dst", ddls); err != nil {
+		t.Fatal(err)
+	}
+	src, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := testutils.NewAnotherSpannerClient(ctx, "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ms []*spanner.Mutation
+	for i := 0; i < 100; i++ {
+		ms = append(ms, spanner.Insert("CopyParent", []string{"ParentID"}, []interface{}{int64(i)}))
+		for j := 0; j < 3; j++ {
+			ms = append(ms, spanner.Insert("CopyChild", []string{"ParentID", "ChildID"}, []interface{}{int64(i), int64(j)}))
+			ms = append(ms, spanner.Insert("CopyGrandChild", []string{"ParentID", "ChildID", "GrandChildID"}, []interface{}{int64(i), int64(j), int64(0)}))
+		}
+	}
+	if _, err := src.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := spankeys.CopyDatabase(ctx, src, dst, &spankeys.CopyOptions{Concurrency: 2, BatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 10, len(report.CopiedRanges))
+	assert.Equal(t, int64(700), report.CopiedRowCount)
+	assert.Equal(t, map[string]int64{"CopyParent": 100, "CopyChild": 300, "CopyGrandChild": 300}, report.TableRowCounts)
+
+	for table, expected := range report.TableRowCounts {
+		cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s", table), dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, cnt)
+	}
+}
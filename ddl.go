@@ -0,0 +1,241 @@
+package spankeys
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+)
+
+// Schema is the schema of a database.
+type Schema struct {
+	// Tables are in parent-before-child order, with their Columns and PrimaryKey.
+	Tables []*Table
+
+	// Indexes are the secondary indexes sorted by the table order and the name.
+	Indexes []*Index
+
+	ForeignKeys []*ForeignKey
+}
+
+// GetSchema reads the schema of all tables in the database from INFORMATION_SCHEMA.
+func GetSchema(ctx context.Context, client *spanner.Client) (*Schema, error) {
+	tables, err := GetTables(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := BuildInterleaveTree(tables)
+	if err != nil {
+		return nil, err
+	}
+	schema := &Schema{}
+	for _, root := range roots {
+		schema.Tables = append(schema.Tables, root.Tables()...)
+	}
+	for _, t := range schema.Tables {
+		cols, err := GetColumns(ctx, client, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		pks, err := GetPrimaryKeyColumns(ctx, client, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		t.Columns, t.PrimaryKey = cols, pks
+	}
+
+	idxes, err := GetIndexes(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range idxes {
+		// the managed indexes are created by the foreign keys
+		if !idx.IsPrimaryKey && !idx.IsManaged {
+			schema.Indexes = append(schema.Indexes, idx)
+		}
+	}
+	schema.sortIndexes()
+
+	fks, err := GetForeignKeys(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	schema.ForeignKeys = fks
+	return schema, nil
+}
+
+// DumpDDL returns the DDL statements to recreate the schema of the database.
+func DumpDDL(ctx context.Context, client *spanner.Client) ([]string, error) {
+	schema, err := GetSchema(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return schema.DDL(), nil
+}
+
+// Table returns the table of the name, or nil if not found.
+func (s *Schema) Table(name string) *Table {
+	for _, t := range s.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// DDL returns the CREATE TABLE and CREATE INDEX statements of the schema in the order of applying.
+// Foreign keys are added by ALTER TABLE after all tables are created.
+func (s *Schema) DDL() []string {
+	var stmts []string
+	for _, t := range s.Tables {
+		stmts = append(stmts, CreateTableDDL(t))
+	}
+	for _, idx := range s.Indexes {
+		stmts = append(stmts, CreateIndexDDL(idx))
+	}
+	for _, fk := range s.ForeignKeys {
+		stmts = append(stmts, AddForeignKeyDDL(fk))
+	}
+	return stmts
+}
+
+func (s *Schema) sortIndexes() {
+	order := make(map[string]int, len(s.Tables))
+	for i, t := range s.Tables {
+		order[t.Name] = i
+	}
+	sort.Slice(s.Indexes, func(i, j int) bool {
+		a, b := s.Indexes[i], s.Indexes[j]
+		if order[a.Table] != order[b.Table] {
+			return order[a.Table] < order[b.Table]
+		}
+		return a.Name < b.Name
+	})
+}
+
+// CreateTableDDL returns the CREATE TABLE statement of the table with its Columns and PrimaryKey.
+func CreateTableDDL(t *Table) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (\n", quoteIdentifier(t.Name))
+	for _, col := range t.Columns {
+		fmt.Fprintf(&b, "  %s,\n", columnDefinition(col))
+	}
+	var keys []string
+	for _, pk := range t.PrimaryKey {
+		keys = append(keys, keyPartDefinition(pk))
+	}
+	fmt.Fprintf(&b, ") PRIMARY KEY (%s)", strings.Join(keys, ", "))
+	if t.Interleave != nil {
+		fmt.Fprintf(&b, ",\n  INTERLEAVE IN PARENT %s ON DELETE %s", quoteIdentifier(t.Interleave.Table), t.Interleave.OnDelete)
+	}
+	return b.String()
+}
+
+// CreateIndexDDL returns the CREATE INDEX statement of the secondary index.
+// The columns without OrdinalPosition are the STORING columns.
+func CreateIndexDDL(idx *Index) string {
+	var b strings.Builder
+	b.WriteString("CREATE ")
+	if idx.IsUnique {
+		b.WriteString("UNIQUE ")
+	}
+	if idx.IsNullFiltered {
+		b.WriteString("NULL_FILTERED ")
+	}
+	var keys, storing []string
+	for _, col := range idx.Columns {
+		if col.OrdinalPosition.Valid {
+			keys = append(keys, keyPartDefinition(&col.Column))
+		} else {
+			storing = append(storing, quoteIdentifier(col.Name))
+		}
+	}
+	fmt.Fprintf(&b, "INDEX %s ON %s (%s)", quoteIdentifier(idx.Name), quoteIdentifier(idx.Table), strings.Join(keys, ", "))
+	if len(storing) > 0 {
+		sort.Strings(storing)
+		fmt.Fprintf(&b, " STORING (%s)", strings.Join(storing, ", "))
+	}
+	if idx.ParentTable != "" {
+		fmt.Fprintf(&b, ", INTERLEAVE IN %s", quoteIdentifier(idx.ParentTable))
+	}
+	return b.String()
+}
+
+// AddForeignKeyDDL returns the ALTER TABLE statement adding the foreign key.
+// An unnamed foreign key is added without the name, so that Cloud Spanner generates it.
+func AddForeignKeyDDL(fk *ForeignKey) string {
+	constraint := ""
+	if !fk.IsUnnamed {
+		constraint = fmt.Sprintf("CONSTRAINT %s ", quoteIdentifier(fk.Name))
+	}
+	return fmt.Sprintf("ALTER TABLE %s ADD %sFOREIGN KEY (%s) REFERENCES %s (%s)",
+		quoteIdentifier(fk.Table), constraint, quoteIdentifiers(fk.Columns),
+		quoteIdentifier(fk.ReferencedTable), quoteIdentifiers(fk.ReferencedColumns))
+}
+
+func (d OnDelete) String() string {
+	if d == OnDeleteCascade {
+		return "CASCADE"
+	}
+	return "NO ACTION"
+}
+
+func columnDefinition(col *Column) string {
+	def := fmt.Sprintf("%s %s", quoteIdentifier(col.Name), columnTypeString(col))
+	if !col.IsNullable {
+		def += " NOT NULL"
+	}
+	if col.IsGenerated() {
+		def += fmt.Sprintf(" AS (%s)", col.GenerationExpression)
+		if col.IsStored {
+			def += " STORED"
+		}
+	} else if col.Default != "" {
+		def += fmt.Sprintf(" DEFAULT (%s)", col.Default)
+	}
+	if col.AllowCommitTimestamp {
+		def += " OPTIONS (allow_commit_timestamp=true)"
+	}
+	return def
+}
+
+func keyPartDefinition(col *Column) string {
+	if col.IsDesc() {
+		return quoteIdentifier(col.Name) + " DESC"
+	}
+	return quoteIdentifier(col.Name)
+}
+
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdentifier(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// quoteIdentifier quotes the identifier by backticks only if it is a reserved keyword.
+func quoteIdentifier(name string) string {
+	if _, ok := reservedKeywords[strings.ToUpper(name)]; ok {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+// reservedKeywords are the reserved keywords of Cloud Spanner SQL.
+// https://cloud.google.com/spanner/docs/lexical#reserved_keywords
+var reservedKeywords = func() map[string]struct{} {
+	m := make(map[string]struct{})
+	for _, kw := range strings.Fields(`
+ALL AND ANY ARRAY AS ASC ASSERT_ROWS_MODIFIED AT BETWEEN BY CASE CAST COLLATE CONTAINS CREATE CROSS CUBE
+CURRENT DEFAULT DEFINE DESC DISTINCT ELSE END ENUM ESCAPE EXCEPT EXCLUDE EXISTS EXTRACT FALSE FETCH FOLLOWING
+FOR FROM FULL GROUP GROUPING GROUPS HASH HAVING IF IGNORE IN INNER INTERSECT INTERVAL INTO IS JOIN LATERAL
+LEFT LIKE LIMIT LOOKUP MERGE NATURAL NEW NO NOT NULL NULLS OF ON OR ORDER OUTER OVER PARTITION PRECEDING
+PROTO RANGE RECURSIVE RESPECT RIGHT ROLLUP ROWS SELECT SET SOME STRUCT TABLESAMPLE THEN TO TREAT TRUE
+UNBOUNDED UNION UNNEST USING WHEN WHERE WINDOW WITH WITHIN`) {
+		m[kw] = struct{}{}
+	}
+	return m
+}()
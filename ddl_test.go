@@ -0,0 +1,139 @@
+package spankeys_test
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+	"github.com/castaneai/spankeys/testutils"
+)
+
+func TestCreateDDL(t *testing.T) {
+	table := &spankeys.Table{
+		Name:       "Order",
+		Interleave: &spankeys.Interleave{Table: "Customer", OnDelete: spankeys.OnDeleteCascade},
+		Columns: []*spankeys.Column{
+			{Name: "CustomerID", SpannerType: "INT64"},
+			{Name: "OrderID", SpannerType: "STRING(36)"},
+			{Name: "Note", SpannerType: "STRING(MAX)", IsNullable: true},
+			{Name: "UpperNote", SpannerType: "STRING(MAX)", IsNullable: true, GenerationExpression: "UPPER(Note)", IsStored: true},
+			{Name: "Status", SpannerType: "STRING(16)", Default: `"new"`},
+			{Name: "UpdatedAt", SpannerType: "TIMESTAMP", AllowCommitTimestamp: true},
+		},
+		PrimaryKey: []*spankeys.Column{
+			{Name: "CustomerID", Ordering: spankeys.ColumnOrderingAsc},
+			{Name: "OrderID", Ordering: spankeys.ColumnOrderingDesc},
+		},
+	}
+	assert.Equal(t, "CREATE TABLE `Order` (\n"+
+		"  CustomerID INT64 NOT NULL,\n"+
+		"  OrderID STRING(36) NOT NULL,\n"+
+		"  Note STRING(MAX),\n"+
+		"  UpperNote STRING(MAX) AS (UPPER(Note)) STORED,\n"+
+		"  Status STRING(16) NOT NULL DEFAULT (\"new\"),\n"+
+		"  UpdatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),\n"+
+		") PRIMARY KEY (CustomerID, OrderID DESC),\n"+
+		"  INTERLEAVE IN PARENT Customer ON DELETE CASCADE", spankeys.CreateTableDDL(table))
+
+	idx := &spankeys.Index{
+		Name:           "OrderByNote",
+		Table:          "Order",
+		ParentTable:    "Customer",
+		IsUnique:       true,
+		IsNullFiltered: true,
+		Columns: []*spankeys.IndexColumn{
+			{Column: spankeys.Column{Name: "CustomerID", OrdinalPosition: spanner.NullInt64{Int64: 1, Valid: true}}},
+			{Column: spankeys.Column{Name: "Note", OrdinalPosition: spanner.NullInt64{Int64: 2, Valid: true}, Ordering: spankeys.ColumnOrderingDesc}},
+			{Column: spankeys.Column{Name: "OrderID"}},
+		},
+	}
+	assert.Equal(t, "CREATE UNIQUE NULL_FILTERED INDEX OrderByNote ON `Order` (CustomerID, Note DESC) STORING (OrderID), INTERLEAVE IN Customer", spankeys.CreateIndexDDL(idx))
+
+	fk := &spankeys.ForeignKey{Name: "FK_OrderCustomer", Table: "Order", Columns: []string{"CustomerID"}, ReferencedTable: "Customer", ReferencedColumns: []string{"ID"}}
+	assert.Equal(t, "ALTER TABLE `Order` ADD CONSTRAINT FK_OrderCustomer FOREIGN KEY (CustomerID) REFERENCES Customer (ID)", spankeys.AddForeignKeyDDL(fk))
+	fk.IsUnnamed = true
+	assert.Equal(t, "ALTER TABLE `Order` ADD FOREIGN KEY (CustomerID) REFERENCES Customer (ID)", spankeys.AddForeignKeyDDL(fk))
+}
+
+func TestDumpDDL(t *testing.T) {
+	ddls := []string{`
+CREATE TABLE DDLParent (
+    ParentID INT64 NOT NULL,
+    Name STRING(255),
+    Data BYTES(MAX) NOT NULL,
+    Tags ARRAY<STRING(MAX)>,
+) PRIMARY KEY (ParentID DESC)
+`, `
+CREATE TABLE DDLChild (
+    ParentID INT64 NOT NULL,
+    ChildID STRING(36) NOT NULL,
+    Score FLOAT64,
+    Note STRING(MAX),
+) PRIMARY KEY (ParentID DESC, ChildID),
+INTERLEAVE IN PARENT DDLParent ON DELETE CASCADE
+`, `
+CREATE UNIQUE NULL_FILTERED INDEX DDLChildByScore ON DDLChild(ParentID DESC, Score DESC) STORING (Note), INTERLEAVE IN DDLParent
+`, `
+CREATE INDEX DDLParentByName ON DDLParent(Name)
+`, `
+CREATE TABLE DDLRef (
+    RefID INT64 NOT NULL,
+    ParentID INT64,
+    CONSTRAINT FK_DDLRef_DDLParent FOREIGN KEY (ParentID) REFERENCES DDLParent (ParentID),
+) PRIMARY KEY (RefID)
+`}
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, ddls); err != nil {
+		t.Fatal(err)
+	}
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dumped, err := spankeys.DumpDDL(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{
+		"CREATE TABLE DDLParent (\n" +
+			"  ParentID INT64 NOT NULL,\n" +
+			"  Name STRING(255),\n" +
+			"  Data BYTES(MAX) NOT NULL,\n" +
+			"  Tags ARRAY<STRING(MAX)>,\n" +
+			") PRIMARY KEY (ParentID DESC)",
+		"CREATE TABLE DDLChild (\n" +
+			"  ParentID INT64 NOT NULL,\n" +
+			"  ChildID STRING(36) NOT NULL,\n" +
+			"  Score FLOAT64,\n" +
+			"  Note STRING(MAX),\n" +
+			") PRIMARY KEY (ParentID DESC, ChildID),\n" +
+			"  INTERLEAVE IN PARENT DDLParent ON DELETE CASCADE",
+		"CREATE TABLE DDLRef (\n" +
+			"  RefID INT64 NOT NULL,\n" +
+			"  ParentID INT64,\n" +
+			") PRIMARY KEY (RefID)",
+		"CREATE INDEX DDLParentByName ON DDLParent (Name)",
+		"CREATE UNIQUE NULL_FILTERED INDEX DDLChildByScore ON DDLChild (ParentID DESC, Score DESC) STORING (Note), INTERLEAVE IN DDLParent",
+		// the backing index of the foreign key is not dumped
+		"ALTER TABLE DDLRef ADD CONSTRAINT FK_DDLRef_DDLParent FOREIGN KEY (ParentID) REFERENCES DDLParent (ParentID)",
+	}, dumped)
+
+	// the dumped DDL can be applied again
+	if err := testutils.PrepareAnotherDatabase(ctx, "ddl", dumped); err != nil {
+		t.Fatal(err)
+	}
+	ac, err := testutils.NewAnotherSpannerClient(ctx, "ddl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	redumped, err := spankeys.DumpDDL(ctx, ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, dumped, redumped)
+}
@@ -0,0 +1,827 @@
+package spankeys
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DDLParseError is an error at the position in the DDL.
+type DDLParseError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *DDLParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// ParseDDL parses the DDL statements separated by semicolons, and builds the same schema as GetSchema.
+// The statements are applied in order, so a DDL file and its migrations can be parsed together.
+// Supported statements are CREATE TABLE, CREATE INDEX, DROP TABLE, DROP INDEX and
+// ALTER TABLE (ADD COLUMN, DROP COLUMN, ALTER COLUMN, ADD CONSTRAINT, DROP CONSTRAINT and SET ON DELETE).
+// Dropping a table or a column still used by an index, a foreign key or the primary key is an error, as in Cloud Spanner.
+// An unnamed foreign key is named FK_<table>_<referenced table>_<n> with IsUnnamed,
+// because the name generated by Cloud Spanner is not known.
+func ParseDDL(ddl string) (*Schema, error) {
+	toks, err := tokenizeDDL(ddl)
+	if err != nil {
+		return nil, err
+	}
+	p := &ddlParser{src: ddl, toks: toks, schema: &Schema{}}
+	for !p.peekKind(ddlTokenEOF) {
+		if p.consumeSymbol(";") {
+			continue
+		}
+		if err := p.parseStatement(); err != nil {
+			return nil, err
+		}
+		if !p.consumeSymbol(";") && !p.peekKind(ddlTokenEOF) {
+			return nil, p.errorf("';' expected")
+		}
+	}
+	if err := p.schema.normalize(); err != nil {
+		return nil, err
+	}
+	return p.schema, nil
+}
+
+// ParseDDLStatements is the same as ParseDDL, but parses the statements such as the ones returned by DumpDDL.
+func ParseDDLStatements(stmts []string) (*Schema, error) {
+	return ParseDDL(strings.Join(stmts, ";\n"))
+}
+
+// normalize orders the tables and the indexes in the same way as GetSchema, and sets the derived fields of the columns.
+func (s *Schema) normalize() error {
+	roots, err := BuildInterleaveTree(s.Tables)
+	if err != nil {
+		return err
+	}
+	var tables []*Table
+	for _, root := range roots {
+		tables = append(tables, root.Tables()...)
+	}
+	s.Tables = tables
+	for _, t := range s.Tables {
+		for i, col := range t.Columns {
+			col.OrdinalPosition.Int64, col.OrdinalPosition.Valid = int64(i+1), true
+		}
+		for i, pk := range t.PrimaryKey {
+			if !containsColumn(t.Columns, pk.Name) {
+				return fmt.Errorf("primary key column %s not found in table %s", pk.Name, t.Name)
+			}
+			pk.OrdinalPosition.Int64, pk.OrdinalPosition.Valid = int64(i+1), true
+		}
+	}
+	for _, idx := range s.Indexes {
+		t := s.Table(idx.Table)
+		if t == nil {
+			return fmt.Errorf("table %s of index %s not found", idx.Table, idx.Name)
+		}
+		cols := columnsByName(t.Columns)
+		pos := int64(0)
+		for _, ic := range idx.Columns {
+			col, ok := cols[ic.Name]
+			if !ok {
+				return fmt.Errorf("column %s of index %s not found in table %s", ic.Name, idx.Name, idx.Table)
+			}
+			ic.IsNullable = col.IsNullable
+			if ic.Ordering != ColumnOrderingUnknown {
+				pos++
+				ic.OrdinalPosition.Int64, ic.OrdinalPosition.Valid = pos, true
+			}
+		}
+	}
+	s.sortIndexes()
+	return nil
+}
+
+type ddlTokenKind int
+
+const (
+	ddlTokenEOF ddlTokenKind = iota
+	ddlTokenIdent
+	ddlTokenNumber
+	ddlTokenString
+	ddlTokenSymbol
+)
+
+type ddlToken struct {
+	kind ddlTokenKind
+	text string
+	// quoted is true for an identifier quoted by backticks, which is never a keyword.
+	quoted     bool
+	start, end int
+}
+
+func tokenizeDDL(src string) ([]ddlToken, error) {
+	var toks []ddlToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || c == '-' && strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, ddlErrorAt(src, i, "unterminated comment")
+			}
+			i += end + 4
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentPart(src[i]) {
+				i++
+			}
+			toks = append(toks, ddlToken{kind: ddlTokenIdent, text: src[start:i], start: start, end: i})
+		case '0' <= c && c <= '9':
+			start := i
+			for i < len(src) && (isIdentPart(src[i]) || src[i] == '.') {
+				i++
+			}
+			toks = append(toks, ddlToken{kind: ddlTokenNumber, text: src[start:i], start: start, end: i})
+		case c == '`' || c == '\'' || c == '"':
+			start := i
+			i++
+			for i < len(src) && src[i] != c {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, ddlErrorAt(src, start, "unterminated quote")
+			}
+			i++
+			if c == '`' {
+				toks = append(toks, ddlToken{kind: ddlTokenIdent, text: src[start+1 : i-1], quoted: true, start: start, end: i})
+			} else {
+				toks = append(toks, ddlToken{kind: ddlTokenString, text: src[start:i], start: start, end: i})
+			}
+		default:
+			toks = append(toks, ddlToken{kind: ddlTokenSymbol, text: string(c), start: i, end: i + 1})
+			i++
+		}
+	}
+	return append(toks, ddlToken{kind: ddlTokenEOF, start: len(src), end: len(src)}), nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || '0' <= c && c <= '9'
+}
+
+func ddlErrorAt(src string, offset int, msg string) error {
+	line := strings.Count(src[:offset], "\n") + 1
+	col := offset - strings.LastIndex(src[:offset], "\n")
+	return &DDLParseError{Line: line, Column: col, Msg: msg}
+}
+
+type ddlParser struct {
+	src    string
+	toks   []ddlToken
+	pos    int
+	schema *Schema
+}
+
+func (p *ddlParser) parseStatement() error {
+	switch {
+	case p.consumeKeywords("CREATE", "TABLE"):
+		return p.parseCreateTable()
+	case p.peekKeyword("CREATE"):
+		return p.parseCreateIndex()
+	case p.consumeKeywords("ALTER", "TABLE"):
+		return p.parseAlterTable()
+	case p.consumeKeywords("DROP", "TABLE"):
+		return p.dropTable()
+	case p.consumeKeywords("DROP", "INDEX"):
+		return p.dropIndex()
+	}
+	return p.errorf("unsupported statement")
+}
+
+func (p *ddlParser) parseCreateTable() error {
+	nameTok := p.peek()
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if p.schema.Table(name) != nil {
+		return p.errorfAt(nameTok, "table %s already exists", name)
+	}
+	t := &Table{Name: name}
+	if err := p.expectSymbol("("); err != nil {
+		return err
+	}
+	for !p.consumeSymbol(")") {
+		if p.peekKeyword("CONSTRAINT") || p.peekKeyword("FOREIGN") || p.peekKeyword("CHECK") {
+			fk, err := p.parseForeignKey(name)
+			if err != nil {
+				return err
+			}
+			p.schema.ForeignKeys = append(p.schema.ForeignKeys, fk)
+		} else {
+			col, err := p.parseColumnDef()
+			if err != nil {
+				return err
+			}
+			t.Columns = append(t.Columns, col)
+		}
+		if !p.consumeSymbol(",") {
+			if err := p.expectSymbol(")"); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	if !p.consumeKeywords("PRIMARY", "KEY") {
+		return p.errorf("PRIMARY KEY expected")
+	}
+	keys, err := p.parseKeyParts()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		t.PrimaryKey = append(t.PrimaryKey, &key.Column)
+	}
+	if p.consumeSymbol(",") {
+		if !p.consumeKeywords("INTERLEAVE", "IN", "PARENT") {
+			return p.errorf("INTERLEAVE IN PARENT expected")
+		}
+		parentTok := p.peek()
+		parent, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if p.schema.Table(parent) == nil {
+			return p.errorfAt(parentTok, "parent table %s not found", parent)
+		}
+		t.Interleave = &Interleave{Table: parent}
+		if p.consumeKeywords("ON", "DELETE") {
+			onDelete, err := p.parseOnDelete()
+			if err != nil {
+				return err
+			}
+			t.Interleave.OnDelete = onDelete
+		}
+	}
+	p.schema.Tables = append(p.schema.Tables, t)
+	return nil
+}
+
+func (p *ddlParser) parseCreateIndex() error {
+	p.next() // CREATE
+	idx := &Index{Type: IndexTypeIndex, State: IndexStateReadWrite}
+	if p.consumeKeywords("UNIQUE") {
+		idx.IsUnique = true
+	}
+	if p.consumeKeywords("NULL_FILTERED") {
+		idx.IsNullFiltered = true
+	}
+	if !p.consumeKeywords("INDEX") {
+		return p.errorf("TABLE or INDEX expected")
+	}
+	nameTok := p.peek()
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if _, ok := indexesByName(p.schema.Indexes)[name]; ok {
+		return p.errorfAt(nameTok, "index %s already exists", name)
+	}
+	idx.Name = name
+	if !p.consumeKeywords("ON") {
+		return p.errorf("ON expected")
+	}
+	tableTok := p.peek()
+	table, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if p.schema.Table(table) == nil {
+		return p.errorfAt(tableTok, "table %s not found", table)
+	}
+	idx.Table = table
+	keys, err := p.parseKeyParts()
+	if err != nil {
+		return err
+	}
+	idx.Columns = keys
+	if p.consumeKeywords("STORING") {
+		names, err := p.parseIdentList()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			idx.Columns = append(idx.Columns, &IndexColumn{Column{Name: name}})
+		}
+	}
+	if p.consumeSymbol(",") {
+		if !p.consumeKeywords("INTERLEAVE", "IN") {
+			return p.errorf("INTERLEAVE IN expected")
+		}
+		parent, err := p.ident()
+		if err != nil {
+			return err
+		}
+		idx.ParentTable = parent
+	}
+	p.schema.Indexes = append(p.schema.Indexes, idx)
+	return nil
+}
+
+func (p *ddlParser) parseAlterTable() error {
+	nameTok := p.peek()
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	t := p.schema.Table(name)
+	if t == nil {
+		return p.errorfAt(nameTok, "table %s not found", name)
+	}
+	switch {
+	case p.consumeKeywords("ADD", "COLUMN"):
+		colTok := p.peek()
+		col, err := p.parseColumnDef()
+		if err != nil {
+			return err
+		}
+		if containsColumn(t.Columns, col.Name) {
+			return p.errorfAt(colTok, "column %s already exists", col.Name)
+		}
+		t.Columns = append(t.Columns, col)
+	case p.consumeKeywords("ADD"):
+		fk, err := p.parseForeignKey(name)
+		if err != nil {
+			return err
+		}
+		p.schema.ForeignKeys = append(p.schema.ForeignKeys, fk)
+	case p.consumeKeywords("DROP", "COLUMN"):
+		colTok := p.peek()
+		col, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if !containsColumn(t.Columns, col) {
+			return p.errorfAt(colTok, "column %s not found", col)
+		}
+		if err := p.checkColumnDroppable(colTok, t, col); err != nil {
+			return err
+		}
+		var cols []*Column
+		for _, c := range t.Columns {
+			if c.Name != col {
+				cols = append(cols, c)
+			}
+		}
+		t.Columns = cols
+	case p.consumeKeywords("DROP", "CONSTRAINT"):
+		fkTok := p.peek()
+		fkName, err := p.ident()
+		if err != nil {
+			return err
+		}
+		var fks []*ForeignKey
+		for _, fk := range p.schema.ForeignKeys {
+			if fk.Name != fkName {
+				fks = append(fks, fk)
+			}
+		}
+		if len(fks) == len(p.schema.ForeignKeys) {
+			return p.errorfAt(fkTok, "constraint %s not found", fkName)
+		}
+		p.schema.ForeignKeys = fks
+	case p.consumeKeywords("SET", "ON", "DELETE"):
+		if t.Interleave == nil {
+			return p.errorf("table %s is not interleaved", name)
+		}
+		onDelete, err := p.parseOnDelete()
+		if err != nil {
+			return err
+		}
+		t.Interleave.OnDelete = onDelete
+	case p.consumeKeywords("ALTER", "COLUMN"):
+		return p.parseAlterColumn(t)
+	default:
+		return p.errorf("unsupported ALTER TABLE")
+	}
+	return nil
+}
+
+func (p *ddlParser) parseAlterColumn(t *Table) error {
+	nameTok := p.peek()
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	col := columnsByName(t.Columns)[name]
+	if col == nil {
+		return p.errorfAt(nameTok, "column %s not found", name)
+	}
+	switch {
+	case p.consumeKeywords("SET", "OPTIONS"):
+		return p.parseColumnOptions(col)
+	case p.consumeKeywords("SET", "DEFAULT"):
+		expr, err := p.parenthesized()
+		if err != nil {
+			return err
+		}
+		col.Default = expr
+		return nil
+	case p.consumeKeywords("DROP", "DEFAULT"):
+		col.Default = ""
+		return nil
+	}
+	typ, err := p.parseType()
+	if err != nil {
+		return err
+	}
+	col.Type, col.SpannerType = typ, typ.String()
+	col.IsNullable = true
+	if p.consumeKeywords("NOT", "NULL") {
+		col.IsNullable = false
+	}
+	if p.consumeKeywords("DEFAULT") {
+		expr, err := p.parenthesized()
+		if err != nil {
+			return err
+		}
+		col.Default = expr
+	}
+	return nil
+}
+
+func (p *ddlParser) parseColumnDef() (*Column, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	typ, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	col := &Column{Name: name, Type: typ, SpannerType: typ.String(), IsNullable: true}
+	if p.consumeKeywords("NOT", "NULL") {
+		col.IsNullable = false
+	}
+	switch {
+	case p.consumeKeywords("DEFAULT"):
+		expr, err := p.parenthesized()
+		if err != nil {
+			return nil, err
+		}
+		col.Default = expr
+	case p.consumeKeywords("AS"):
+		expr, err := p.parenthesized()
+		if err != nil {
+			return nil, err
+		}
+		col.GenerationExpression = expr
+		col.IsStored = p.consumeKeywords("STORED")
+	}
+	if p.consumeKeywords("OPTIONS") {
+		if err := p.parseColumnOptions(col); err != nil {
+			return nil, err
+		}
+	}
+	return col, nil
+}
+
+// parseColumnOptions parses "(allow_commit_timestamp = true|false|null)".
+func (p *ddlParser) parseColumnOptions(col *Column) error {
+	if err := p.expectSymbol("("); err != nil {
+		return err
+	}
+	for !p.consumeSymbol(")") {
+		opt, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if err := p.expectSymbol("="); err != nil {
+			return err
+		}
+		value := strings.ToUpper(p.next().text)
+		if !strings.EqualFold(opt, "allow_commit_timestamp") {
+			return p.errorf("unsupported option: %s", opt)
+		}
+		col.AllowCommitTimestamp = value == "TRUE"
+		if !p.consumeSymbol(",") {
+			if err := p.expectSymbol(")"); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// parseType parses the tokens of the type until NOT, DEFAULT, AS, OPTIONS, ',' or ')' at the top level.
+func (p *ddlParser) parseType() (*SpannerType, error) {
+	start := p.peek().start
+	end := start
+	depth := 0
+	for {
+		tok := p.peek()
+		if tok.kind == ddlTokenEOF || tok.kind == ddlTokenSymbol && tok.text == ";" {
+			break
+		}
+		if depth == 0 && (tok.kind == ddlTokenSymbol && (tok.text == "," || tok.text == ")") ||
+			p.peekKeyword("NOT") || p.peekKeyword("DEFAULT") || p.peekKeyword("AS") || p.peekKeyword("OPTIONS")) {
+			break
+		}
+		if tok.kind == ddlTokenSymbol {
+			switch tok.text {
+			case "(", "<":
+				depth++
+			case ")", ">":
+				depth--
+			}
+		}
+		end = tok.end
+		p.next()
+	}
+	typ, err := ParseSpannerType(p.src[start:end])
+	if err != nil {
+		return nil, ddlErrorAt(p.src, start, err.Error())
+	}
+	return typ, nil
+}
+
+// parseKeyParts parses "(col [ASC|DESC], ...)".
+func (p *ddlParser) parseKeyParts() ([]*IndexColumn, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var keys []*IndexColumn
+	for !p.consumeSymbol(")") {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		ordering := ColumnOrderingAsc
+		if p.consumeKeywords("DESC") {
+			ordering = ColumnOrderingDesc
+		} else {
+			p.consumeKeywords("ASC")
+		}
+		keys = append(keys, &IndexColumn{Column{Name: name, Ordering: ordering}})
+		if !p.consumeSymbol(",") {
+			if err := p.expectSymbol(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return keys, nil
+}
+
+// parseForeignKey parses "[CONSTRAINT name] FOREIGN KEY (cols) REFERENCES table (cols)".
+// CHECK constraints are not supported because Schema has no model of them.
+func (p *ddlParser) parseForeignKey(table string) (*ForeignKey, error) {
+	fk := &ForeignKey{Table: table}
+	if p.consumeKeywords("CONSTRAINT") {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		fk.Name = name
+	}
+	if p.peekKeyword("CHECK") {
+		return nil, p.errorf("unsupported: CHECK constraint")
+	}
+	if !p.consumeKeywords("FOREIGN", "KEY") {
+		return nil, p.errorf("FOREIGN KEY expected")
+	}
+	cols, err := p.parseIdentList()
+	if err != nil {
+		return nil, err
+	}
+	fk.Columns = cols
+	if !p.consumeKeywords("REFERENCES") {
+		return nil, p.errorf("REFERENCES expected")
+	}
+	if fk.ReferencedTable, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if fk.ReferencedColumns, err = p.parseIdentList(); err != nil {
+		return nil, err
+	}
+	if fk.Name == "" {
+		fk.IsUnnamed = true
+		names := foreignKeysByName(p.schema.ForeignKeys)
+		for n := 1; ; n++ {
+			fk.Name = fmt.Sprintf("FK_%s_%s_%d", fk.Table, fk.ReferencedTable, n)
+			if _, ok := names[fk.Name]; !ok {
+				break
+			}
+		}
+	}
+	return fk, nil
+}
+
+func (p *ddlParser) parseOnDelete() (OnDelete, error) {
+	switch {
+	case p.consumeKeywords("CASCADE"):
+		return OnDeleteCascade, nil
+	case p.consumeKeywords("NO", "ACTION"):
+		return OnDeleteNoAction, nil
+	}
+	return OnDeleteNoAction, p.errorf("CASCADE or NO ACTION expected")
+}
+
+func (p *ddlParser) parseIdentList() ([]string, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.consumeSymbol(",") {
+			break
+		}
+	}
+	return names, p.expectSymbol(")")
+}
+
+// parenthesized returns the source text of the expression in the balanced parentheses.
+func (p *ddlParser) parenthesized() (string, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return "", err
+	}
+	start := p.peek().start
+	depth := 1
+	for {
+		tok := p.next()
+		if tok.kind == ddlTokenEOF {
+			return "", p.errorf("')' expected")
+		}
+		if tok.kind != ddlTokenSymbol {
+			continue
+		}
+		switch tok.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return strings.TrimSpace(p.src[start:tok.start]), nil
+			}
+		}
+	}
+}
+
+func (p *ddlParser) dropTable() error {
+	nameTok := p.peek()
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	var tables []*Table
+	for _, t := range p.schema.Tables {
+		if t.Interleave != nil && t.Interleave.Table == name {
+			return p.errorfAt(nameTok, "table %s has the interleaved table %s", name, t.Name)
+		}
+		if t.Name != name {
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == len(p.schema.Tables) {
+		return p.errorfAt(nameTok, "table %s not found", name)
+	}
+	// Cloud Spanner refuses to drop a table until its indexes and foreign keys are dropped
+	for _, idx := range p.schema.Indexes {
+		if idx.Table == name {
+			return p.errorfAt(nameTok, "table %s has the index %s", name, idx.Name)
+		}
+	}
+	for _, fk := range p.schema.ForeignKeys {
+		if fk.Table == name || fk.ReferencedTable == name {
+			return p.errorfAt(nameTok, "table %s has the foreign key %s", name, fk.Name)
+		}
+	}
+	p.schema.Tables = tables
+	return nil
+}
+
+// checkColumnDroppable rejects dropping a column used by the primary key, an index or a foreign key, as Cloud Spanner does.
+func (p *ddlParser) checkColumnDroppable(tok ddlToken, t *Table, col string) error {
+	if containsColumn(t.PrimaryKey, col) {
+		return p.errorfAt(tok, "column %s is a primary key column", col)
+	}
+	for _, idx := range p.schema.Indexes {
+		if idx.Table != t.Name {
+			continue
+		}
+		for _, ic := range idx.Columns {
+			if ic.Name == col {
+				return p.errorfAt(tok, "column %s is used by the index %s", col, idx.Name)
+			}
+		}
+	}
+	for _, fk := range p.schema.ForeignKeys {
+		if (fk.Table == t.Name && containsString(fk.Columns, col)) ||
+			(fk.ReferencedTable == t.Name && containsString(fk.ReferencedColumns, col)) {
+			return p.errorfAt(tok, "column %s is used by the foreign key %s", col, fk.Name)
+		}
+	}
+	return nil
+}
+
+func (p *ddlParser) dropIndex() error {
+	nameTok := p.peek()
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	var idxes []*Index
+	for _, idx := range p.schema.Indexes {
+		if idx.Name != name {
+			idxes = append(idxes, idx)
+		}
+	}
+	if len(idxes) == len(p.schema.Indexes) {
+		return p.errorfAt(nameTok, "index %s not found", name)
+	}
+	p.schema.Indexes = idxes
+	return nil
+}
+
+func (p *ddlParser) peek() ddlToken {
+	return p.toks[p.pos]
+}
+
+func (p *ddlParser) next() ddlToken {
+	tok := p.toks[p.pos]
+	if tok.kind != ddlTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *ddlParser) peekKind(kind ddlTokenKind) bool {
+	return p.peek().kind == kind
+}
+
+func (p *ddlParser) peekKeyword(kw string) bool {
+	tok := p.peek()
+	return tok.kind == ddlTokenIdent && !tok.quoted && strings.EqualFold(tok.text, kw)
+}
+
+// consumeKeywords consumes the keywords only if all of them follow.
+func (p *ddlParser) consumeKeywords(kws ...string) bool {
+	for i, kw := range kws {
+		tok := p.toks[p.pos+i]
+		// EOF is not an identifier, so the index never exceeds the tokens
+		if tok.kind != ddlTokenIdent || tok.quoted || !strings.EqualFold(tok.text, kw) {
+			return false
+		}
+	}
+	p.pos += len(kws)
+	return true
+}
+
+func (p *ddlParser) consumeSymbol(s string) bool {
+	tok := p.peek()
+	if tok.kind == ddlTokenSymbol && tok.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *ddlParser) expectSymbol(s string) error {
+	if !p.consumeSymbol(s) {
+		return p.errorf("'%s' expected", s)
+	}
+	return nil
+}
+
+func (p *ddlParser) ident() (string, error) {
+	tok := p.peek()
+	if tok.kind != ddlTokenIdent {
+		return "", p.errorf("identifier expected")
+	}
+	p.pos++
+	return tok.text, nil
+}
+
+func (p *ddlParser) errorf(format string, args ...interface{}) error {
+	return p.errorfAt(p.peek(), format, args...)
+}
+
+// errorfAt reports the error at the token, such as an identifier which is already consumed.
+func (p *ddlParser) errorfAt(tok ddlToken, format string, args ...interface{}) error {
+	return ddlErrorAt(p.src, tok.start, fmt.Sprintf(format, args...))
+}
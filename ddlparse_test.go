@@ -0,0 +1,172 @@
+package spankeys_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+)
+
+const testDDL = `
+-- singers and their albums
+CREATE TABLE Singers (
+    SingerID INT64 NOT NULL,
+    Name STRING(MAX),
+    Status STRING(16) NOT NULL DEFAULT ("active"),
+    UpperName STRING(MAX) AS (UPPER(Name)) STORED,
+    UpdatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp = true),
+) PRIMARY KEY (SingerID);
+
+CREATE TABLE Albums (
+    SingerID INT64 NOT NULL,
+    AlbumID INT64 NOT NULL,
+    Title STRING(1024),
+    Tags ARRAY<STRING(36)>,
+    LabelID INT64,
+    CONSTRAINT FK_AlbumLabel FOREIGN KEY (LabelID) REFERENCES ` + "`Labels`" + ` (LabelID),
+) PRIMARY KEY (SingerID, AlbumID DESC),
+  INTERLEAVE IN PARENT Singers ON DELETE CASCADE;
+
+CREATE TABLE Labels (
+    LabelID INT64 NOT NULL,
+) PRIMARY KEY (LabelID);
+
+/* indexes */
+CREATE UNIQUE NULL_FILTERED INDEX AlbumsByTitle ON Albums (SingerID, Title DESC) STORING (Tags), INTERLEAVE IN Singers;
+CREATE INDEX SingersByName ON Singers (Name);
+`
+
+func TestParseDDL(t *testing.T) {
+	schema, err := spankeys.ParseDDL(testDDL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 3, len(schema.Tables))
+	// tables are in parent-before-child order
+	assert.Equal(t, "Labels", schema.Tables[0].Name)
+	assert.Equal(t, "Singers", schema.Tables[1].Name)
+	assert.Equal(t, "Albums", schema.Tables[2].Name)
+
+	singers := schema.Table("Singers")
+	assert.Nil(t, singers.Interleave)
+	assert.Equal(t, 5, len(singers.Columns))
+	assert.Equal(t, &spankeys.Column{
+		Name:            "SingerID",
+		OrdinalPosition: spanner.NullInt64{Int64: 1, Valid: true},
+		SpannerType:     "INT64",
+		Type:            &spankeys.SpannerType{Code: spankeys.TypeInt64},
+	}, singers.Columns[0])
+	assert.True(t, singers.Columns[1].IsNullable)
+	assert.Equal(t, `"active"`, singers.Columns[2].Default)
+	assert.Equal(t, "UPPER(Name)", singers.Columns[3].GenerationExpression)
+	assert.True(t, singers.Columns[3].IsStored)
+	assert.True(t, singers.Columns[4].AllowCommitTimestamp)
+
+	albums := schema.Table("Albums")
+	assert.Equal(t, &spankeys.Interleave{Table: "Singers", OnDelete: spankeys.OnDeleteCascade}, albums.Interleave)
+	assert.Equal(t, "ARRAY<STRING(36)>", albums.Columns[3].SpannerType)
+	assert.Equal(t, 2, len(albums.PrimaryKey))
+	assert.Equal(t, "AlbumID", albums.PrimaryKey[1].Name)
+	assert.True(t, albums.PrimaryKey[1].IsDesc())
+
+	assert.Equal(t, 2, len(schema.Indexes))
+	idx := schema.Indexes[0]
+	assert.Equal(t, "SingersByName", idx.Name)
+	idx = schema.Indexes[1]
+	assert.Equal(t, "AlbumsByTitle", idx.Name)
+	assert.True(t, idx.IsUnique)
+	assert.True(t, idx.IsNullFiltered)
+	assert.Equal(t, "Singers", idx.ParentTable)
+	assert.Equal(t, 3, len(idx.Columns))
+	assert.Equal(t, spanner.NullInt64{Int64: 2, Valid: true}, idx.Columns[1].OrdinalPosition)
+	assert.True(t, idx.Columns[1].IsDesc())
+	assert.Equal(t, "Tags", idx.Columns[2].Name)
+	assert.False(t, idx.Columns[2].OrdinalPosition.Valid)
+
+	assert.Equal(t, []*spankeys.ForeignKey{
+		{Name: "FK_AlbumLabel", Table: "Albums", Columns: []string{"LabelID"}, ReferencedTable: "Labels", ReferencedColumns: []string{"LabelID"}},
+	}, schema.ForeignKeys)
+
+	// the generated DDL is parsed to the same schema
+	reparsed, err := spankeys.ParseDDLStatements(schema.DDL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, schema, reparsed)
+
+	size, err := schema.CalcBatchSize("Singers", spankeys.MutationDelete)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, spankeys.EstimateBatchSize(spankeys.MutationDelete, singers.Columns, singers.PrimaryKey, 2), size)
+}
+
+func TestParseDDLMigration(t *testing.T) {
+	from, err := spankeys.ParseDDL(testDDL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := spankeys.ParseDDL(testDDL + `
+ALTER TABLE Albums DROP CONSTRAINT FK_AlbumLabel;
+DROP TABLE Labels;
+DROP INDEX SingersByName;
+ALTER TABLE Singers ADD COLUMN Country STRING(2);
+ALTER TABLE Singers ALTER COLUMN Name STRING(1024) NOT NULL;
+ALTER TABLE Singers ALTER COLUMN UpdatedAt SET OPTIONS (allow_commit_timestamp = null);
+ALTER TABLE Albums DROP COLUMN LabelID;
+ALTER TABLE Albums SET ON DELETE NO ACTION;
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, to.Table("Labels"))
+	assert.Equal(t, 6, len(to.Table("Singers").Columns))
+	assert.False(t, to.Table("Singers").Columns[4].AllowCommitTimestamp)
+
+	// applying the diff to the old schema results in the new schema
+	d := spankeys.DiffSchemas(from, to)
+	assert.Empty(t, d.Unsupported)
+	migrated, err := spankeys.ParseDDLStatements(append(from.DDL(), d.Statements...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, spankeys.DiffSchemas(migrated, to).HasChanges())
+}
+
+func TestParseDDLError(t *testing.T) {
+	cases := []struct {
+		ddl  string
+		line int
+		col  int
+	}{
+		{"CREATE TABLE T (\n  ID INT64 NOT NULL\n) PRIMARY (ID)", 3, 3},
+		{"CREATE TABLE T (\n  ID UNKNOWN,\n) PRIMARY KEY (ID)", 2, 6},
+		{"CREATE INDEX I ON Missing (ID)", 1, 19},
+		{"CREATE VIEW V", 1, 8},
+		// the statements refused by Cloud Spanner
+		{testDDL + "DROP TABLE Labels", 28, 12},
+		{testDDL + "DROP TABLE Albums", 28, 12},
+		{testDDL + "ALTER TABLE Albums DROP COLUMN AlbumID", 28, 32},
+		{testDDL + "ALTER TABLE Albums DROP COLUMN Tags", 28, 32},
+		{testDDL + "ALTER TABLE Albums DROP COLUMN LabelID", 28, 32},
+		{"CREATE TABLE T (\n  ID INT64,\n  CONSTRAINT C CHECK (ID > 0),\n) PRIMARY KEY (ID)", 3, 16},
+	}
+	for _, c := range cases {
+		_, err := spankeys.ParseDDL(c.ddl)
+		pe, ok := err.(*spankeys.DDLParseError)
+		if !assert.True(t, ok, "%s: %v", c.ddl, err) {
+			continue
+		}
+		assert.Equal(t, c.line, pe.Line, c.ddl)
+		assert.Equal(t, c.col, pe.Column, c.ddl)
+	}
+
+	_, err := spankeys.ParseDDL("CREATE TABLE T (ID INT64) PRIMARY KEY (Missing)")
+	assert.Error(t, err)
+
+	_, err = spankeys.ParseDDL("CREATE TABLE T (ID INT64) PRIMARY KEY (ID);\nALTER TABLE T ADD CHECK (ID > 0)")
+	assert.EqualError(t, err, "2:19: unsupported: CHECK constraint")
+}
@@ -0,0 +1,129 @@
+package spankeys
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// DefaultMaxRetries is the default max retry count of a transaction aborted by Cloud Spanner.
+	DefaultMaxRetries = 3
+
+	// DefaultDeleteMaxRetries is kept for compatibility. Use DefaultMaxRetries.
+	DefaultDeleteMaxRetries = DefaultMaxRetries
+)
+
+type DeleteOptions struct {
+	// Concurrency is the number of key ranges deleted at the same time (default: 1).
+	Concurrency int
+
+	// MutationBatchSize is the max row count of a key range.
+	// If zero, key ranges are partitioned by mutations including the cascaded descendant rows (see NewCascadeKeyRangeIterator).
+	MutationBatchSize int
+
+	// PageSize is the row count fetched by one query while partitioning (default: DefaultPageSize).
+	PageSize int
+
+	// MaxRetries is the max retry count of a transaction aborted by Cloud Spanner (default: DefaultMaxRetries).
+	MaxRetries int
+
+	// Checkpoint makes the deletion resumable. See RunOptions.
+	Checkpoint CheckpointStore
+
+	// Throttle limits the rate of the deletion. See RunOptions.
+	Throttle *Throttle
+
+	// Observer receives the progress of the deletion. See RunOptions.
+	Observer Observer
+}
+
+type DeleteReport struct {
+	DeletedRanges   []*CountableKeyRange
+	DeletedRowCount int64
+}
+
+// DeleteAllRows deletes all rows of the table by partitioned key ranges.
+// Each key range is deleted in its own transaction.
+// If some key ranges fail, the report of the deleted ranges is returned with RangeErrors.
+func DeleteAllRows(ctx context.Context, client *spanner.Client, tableName string, opts *DeleteOptions) (*DeleteReport, error) {
+	if opts == nil {
+		opts = &DeleteOptions{}
+	}
+	pkCols, err := GetPrimaryKeyColumns(ctx, client, tableName)
+	if err != nil {
+		return nil, err
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries < 1 {
+		maxRetries = DefaultMaxRetries
+	}
+
+	var it *KeyRangeIterator
+	if opts.MutationBatchSize < 1 {
+		it, err = NewCascadeKeyRangeIterator(ctx, client, tableName, pkCols, &KeyRangeOptions{PageSize: opts.PageSize})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		it = NewKeyRangeIterator(ctx, client, tableName, pkCols, &KeyRangeOptions{
+			MutationBatchSize: opts.MutationBatchSize,
+			PageSize:          opts.PageSize,
+		})
+	}
+	run, err := RunKeyRanges(ctx, it, func(ctx context.Context, r *CountableKeyRange) error {
+		return deleteKeyRange(ctx, client, tableName, r, maxRetries)
+	}, &RunOptions{
+		Concurrency: opts.Concurrency,
+		Checkpoint:  opts.Checkpoint,
+		Throttle:    opts.Throttle,
+		Observer:    opts.Observer,
+	})
+	if run == nil {
+		return nil, err
+	}
+	return &DeleteReport{DeletedRanges: run.Completed, DeletedRowCount: run.RowCount}, err
+}
+
+func deleteKeyRange(ctx context.Context, client *spanner.Client, tableName string, r *CountableKeyRange, maxRetries int) error {
+	return runTransactionWithRetry(ctx, client, maxRetries, func(ctx context.Context, tx *spanner.ReadWriteTransaction) (int64, error) {
+		// a key range is deleted by a mutation
+		return 1, tx.BufferWrite([]*spanner.Mutation{spanner.Delete(tableName, r.KeyRange)})
+	})
+}
+
+// runTransactionWithRetry runs f in a read-write transaction, retrying it when aborted.
+// f returns the count of the mutations it buffered, which the throttle waits for before the commit.
+func runTransactionWithRetry(ctx context.Context, client *spanner.Client, maxRetries int, f func(context.Context, *spanner.ReadWriteTransaction) (int64, error)) error {
+	var err error
+	// attempts counts the retries of the client too, which are not visible from the returned error
+	attempts := 0
+	for i := 0; i <= maxRetries; i++ {
+		var committing time.Time
+		_, err = client.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+			attempts++
+			mutations, err := f(ctx, tx)
+			if err != nil {
+				return err
+			}
+			if err := waitCommit(ctx, mutations); err != nil {
+				return err
+			}
+			committing = time.Now()
+			return nil
+		})
+		if err == nil {
+			observeCommit(ctx, time.Since(committing), attempts, attempts-1)
+			return nil
+		}
+		// the client retries aborted transactions internally,
+		// but an Aborted error can still be returned (e.g. the session was lost while committing)
+		if spanner.ErrCode(err) != codes.Aborted {
+			return err
+		}
+	}
+	observeCommit(ctx, 0, attempts, attempts)
+	return err
+}
@@ -0,0 +1,65 @@
+package spankeys_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+	"github.com/castaneai/spankeys/testutils"
+)
+
+func TestDeleteAllRows(t *testing.T) {
+	tableName := "DeleteAllRowsTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID STRING(36) NOT NULL,
+    Name STRING(255) NOT NULL,
+) PRIMARY KEY (ID)
+`, tableName), fmt.Sprintf(`
+CREATE INDEX %s_Name ON %s(Name)
+`, tableName, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := c.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+			var ms []*spanner.Mutation
+			for i := 0; i < 5000; i++ {
+				id := uuid.Must(uuid.NewRandom()).String()
+				name := uuid.Must(uuid.NewRandom()).String()
+				ms = append(ms, spanner.Insert(tableName, []string{"ID", "Name"}, []interface{}{id, name}))
+			}
+			return tx.BufferWrite(ms)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := spankeys.DeleteAllRows(ctx, c, tableName, &spankeys.DeleteOptions{
+		Concurrency: 4,
+		PageSize:    10000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(5*5000), report.DeletedRowCount)
+	assert.True(t, len(report.DeletedRanges) >= 3)
+
+	cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s", tableName), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(0), cnt)
+}
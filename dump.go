@@ -0,0 +1,229 @@
+package spankeys
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/option"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+const (
+	// DumpSchemaFileName is the name of the DDL file written by Dump for reading.
+	// Restore uses the statements in the manifest instead.
+	DumpSchemaFileName = "schema.sql"
+	// DumpManifestFileName is the name of the manifest written by Dump.
+	DumpManifestFileName = "dump.json"
+)
+
+type DumpOptions struct {
+	// Format is the format of the data files (default: ExportFormatJSONL).
+	Format ExportFormat
+
+	// Concurrency is the number of key ranges exported at the same time in each table (default: 1).
+	Concurrency int
+
+	// RowsPerFile is the max row count of a data file (default: DefaultPageSize).
+	RowsPerFile int
+}
+
+// DumpManifest describes a dump. The data of each table is in the directory of the table name, written by ExportTable.
+type DumpManifest struct {
+	Database      string    `json:"database"`
+	ReadTimestamp time.Time `json:"readTimestamp"`
+
+	// Tables are the table names in the order of restoring, where interleave parents come first.
+	Tables []string `json:"tables"`
+
+	// Statements are the DDL statements of the database except ForeignKeys.
+	Statements []string `json:"statements"`
+
+	// ForeignKeys are the ALTER TABLE statements adding foreign keys, which are run after the data is restored,
+	// so that the foreign keys can be cyclic.
+	ForeignKeys []string `json:"foreignKeys,omitempty"`
+}
+
+// Dump writes the DDL and the data of all tables in the database to dir at one read timestamp.
+// The client options are used for both the data client and the database admin client.
+func Dump(ctx context.Context, dsn DSN, dir string, opts *DumpOptions, clientOpts ...option.ClientOption) (*DumpManifest, error) {
+	if opts == nil {
+		opts = &DumpOptions{}
+	}
+	admin, err := database.NewDatabaseAdminClient(ctx, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	defer admin.Close()
+	client, err := spanner.NewClient(ctx, string(dsn), clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	ddl, err := admin.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{Database: string(dsn)})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, DumpSchemaFileName), []byte(joinDDL(ddl.Statements)), 0644); err != nil {
+		return nil, err
+	}
+
+	ts, err := currentTimestamp(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &DumpManifest{Database: dsn.DatabaseID(), ReadTimestamp: ts}
+	// the foreign keys added to existing tables are separated, so that they can be cyclic
+	deferred := map[string]bool{}
+	for _, stmt := range ddl.Statements {
+		m := addForeignKeyPattern.FindStringSubmatch(stmt)
+		if m == nil {
+			manifest.Statements = append(manifest.Statements, stmt)
+			continue
+		}
+		manifest.ForeignKeys = append(manifest.ForeignKeys, stmt)
+		deferred[foreignKeyID(unquoteIdentifier(m[2]), unquoteIdentifier(m[1]), unquoteIdentifier(m[3]))] = true
+	}
+	tables, err := sortedTables(ctx, client, deferred)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		if _, err := ExportTable(ctx, client, t.Name, filepath.Join(dir, t.Name), &ExportOptions{
+			Format:        opts.Format,
+			Concurrency:   opts.Concurrency,
+			RowsPerFile:   opts.RowsPerFile,
+			ReadTimestamp: ts,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to export table %s: %v", t.Name, err)
+		}
+		manifest.Tables = append(manifest.Tables, t.Name)
+	}
+	if err := writeJSONFile(filepath.Join(dir, DumpManifestFileName), manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Restore creates the schema in the empty database and imports the data of the tables dumped by Dump in dir.
+// Interleave parents and tables referenced by the foreign keys in CREATE TABLE are restored first,
+// and the foreign keys added by ALTER TABLE are added after all data is restored.
+func Restore(ctx context.Context, dsn DSN, dir string, clientOpts ...option.ClientOption) (*DumpManifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, DumpManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	var manifest DumpManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	admin, err := database.NewDatabaseAdminClient(ctx, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	defer admin.Close()
+	client, err := spanner.NewClient(ctx, string(dsn), clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	existing, err := GetTables(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, errors.New("the database to restore must be empty")
+	}
+	if err := updateDDL(ctx, admin, dsn, manifest.Statements); err != nil {
+		return nil, err
+	}
+
+	for _, table := range manifest.Tables {
+		tableDir := filepath.Join(dir, table)
+		em, err := ReadExportManifest(tableDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range em.Files {
+			if _, err := ImportFile(ctx, client, table, filepath.Join(tableDir, f.Name), &ImportOptions{Format: em.Format}); err != nil {
+				return nil, fmt.Errorf("failed to import table %s: %v", table, err)
+			}
+		}
+	}
+	if err := updateDDL(ctx, admin, dsn, manifest.ForeignKeys); err != nil {
+		return nil, fmt.Errorf("failed to add the foreign keys: %v", err)
+	}
+	return &manifest, nil
+}
+
+func updateDDL(ctx context.Context, admin *database.DatabaseAdminClient, dsn DSN, stmts []string) error {
+	if len(stmts) < 1 {
+		return nil
+	}
+	op, err := admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{Database: string(dsn), Statements: stmts})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
+
+// addForeignKeyPattern matches ALTER TABLE ... ADD [CONSTRAINT name] FOREIGN KEY (...) REFERENCES table (...)
+// and captures the table, the constraint name and the referenced table.
+var addForeignKeyPattern = regexp.MustCompile("(?is)^\\s*ALTER\\s+TABLE\\s+(`[^`]+`|\\w+)\\s+ADD\\s+(?:CONSTRAINT\\s+(`[^`]+`|\\w+)\\s+)?FOREIGN\\s+KEY\\b.*?\\bREFERENCES\\s+(`[^`]+`|\\w+)")
+
+// sortedTables returns all tables in the order of restoring.
+// The foreign keys in deferred are ignored because they are added after restoring the data.
+func sortedTables(ctx context.Context, client *spanner.Client, deferred map[string]bool) ([]*Table, error) {
+	tables, err := GetTables(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	fks, err := GetForeignKeys(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	var deps []*ForeignKey
+	for _, fk := range fks {
+		if deferred[foreignKeyID(fk.Name, fk.Table, fk.ReferencedTable)] || deferred[foreignKeyID("", fk.Table, fk.ReferencedTable)] {
+			continue
+		}
+		deps = append(deps, fk)
+	}
+	return SortTablesByDependency(tables, deps)
+}
+
+// foreignKeyID identifies a foreign key by the name, or by the tables if the name is not written in the DDL.
+func foreignKeyID(name, table, referencedTable string) string {
+	if name != "" {
+		return name
+	}
+	return table + "\x00" + referencedTable
+}
+
+func unquoteIdentifier(s string) string {
+	return strings.Trim(s, "`")
+}
+
+func joinDDL(stmts []string) string {
+	var b strings.Builder
+	for _, stmt := range stmts {
+		b.WriteString(strings.TrimSpace(stmt))
+		b.WriteString(";\n\n")
+	}
+	return b.String()
+}
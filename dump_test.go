@@ -0,0 +1,130 @@
+package spankeys_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+	"github.com/castaneai/spankeys/testutils"
+)
+
+func TestDumpAndRestore(t *testing.T) {
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{`
+CREATE TABLE DumpParent (
+    ParentID INT64 NOT NULL,
+    Name STRING(MAX),
+) PRIMARY KEY (ParentID)
+`, `
+CREATE TABLE DumpChild (
+    ParentID INT64 NOT NULL,
+    ChildID INT64 NOT NULL,
+) PRIMARY KEY (ParentID, ChildID),
+INTERLEAVE IN PARENT DumpParent ON DELETE CASCADE
+`, `
+CREATE TABLE DumpA (
+    AID INT64 NOT NULL,
+    BID INT64,
+) PRIMARY KEY (AID)
+`, `
+CREATE TABLE DumpB (
+    BID INT64 NOT NULL,
+    AID INT64,
+    Note STRING(MAX) DEFAULT ("a;\nb"),
+    CONSTRAINT FK_DumpB_DumpA FOREIGN KEY (AID) REFERENCES DumpA (AID),
+) PRIMARY KEY (BID)
+`, `ALTER TABLE DumpA ADD CONSTRAINT FK_DumpA_DumpB FOREIGN KEY (BID) REFERENCES DumpB (BID)`,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutils.PrepareAnotherDatabase(ctx, "restore", nil); err != nil {
+		t.Fatal(err)
+	}
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ms []*spanner.Mutation
+	for i := 0; i < 100; i++ {
+		ms = append(ms, spanner.Insert("DumpParent", []string{"ParentID", "Name"}, []interface{}{int64(i), "parent"}))
+		for j := 0; j < 2; j++ {
+			ms = append(ms, spanner.Insert("DumpChild", []string{"ParentID", "ChildID"}, []interface{}{int64(i), int64(j)}))
+		}
+	}
+	// the foreign keys are cyclic
+	ms = append(ms,
+		spanner.Insert("DumpA", []string{"AID", "BID"}, []interface{}{int64(1), int64(1)}),
+		spanner.Insert("DumpB", []string{"BID", "AID"}, []interface{}{int64(1), int64(1)}),
+	)
+	if _, err := c.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "spankeys-dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts, err := testutils.EmulatorClientOptions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := testutils.DSN()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := spankeys.Dump(ctx, src, dir, &spankeys.DumpOptions{RowsPerFile: 30}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"DumpA", "DumpB", "DumpParent", "DumpChild"}, manifest.Tables)
+	// the statements are kept as in the database, and the foreign key added by ALTER TABLE is restored at last
+	assert.Contains(t, strings.Join(manifest.ForeignKeys, "\n"), "FK_DumpA_DumpB")
+	for _, stmt := range manifest.Statements {
+		assert.NotContains(t, stmt, "FK_DumpA_DumpB")
+	}
+
+	dst, err := testutils.AnotherDSN("restore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := spankeys.Restore(ctx, dst, dir, opts...); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := testutils.NewAnotherSpannerClient(ctx, "restore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cnt, err := testutils.CountsRow(ctx, "select count(*) from DumpParent", rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(100), cnt)
+	cnt, err = testutils.CountsRow(ctx, "select count(*) from DumpChild", rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(200), cnt)
+	cnt, err = testutils.CountsRow(ctx, "select count(*) from DumpB where Note = 'a;\\nb'", rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(1), cnt)
+	fks, err := spankeys.GetForeignKeys(ctx, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(fks))
+
+	// the database is not empty anymore
+	_, err = spankeys.Restore(ctx, dst, dir, opts...)
+	assert.Error(t, err)
+}
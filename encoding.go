@@ -0,0 +1,335 @@
+package spankeys
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+)
+
+// jsonKeyRange is the JSON representation of CountableKeyRange.
+// Each key part has its Spanner type, so that the key can be decoded back to the same Go type.
+type jsonKeyRange struct {
+	Start              []*jsonKeyPart   `json:"start"`
+	End                []*jsonKeyPart   `json:"end"`
+	Kind               string           `json:"kind"`
+	RowCount           int64            `json:"rowCount"`
+	DescendantRowCount int64            `json:"descendantRowCount,omitempty"`
+	MutationCount      int64            `json:"mutationCount,omitempty"`
+	Keys               [][]*jsonKeyPart `json:"keys,omitempty"`
+}
+
+type jsonKeyPart struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+var keyRangeKindNames = map[spanner.KeyRangeKind]string{
+	spanner.ClosedOpen:   "ClosedOpen",
+	spanner.ClosedClosed: "ClosedClosed",
+	spanner.OpenClosed:   "OpenClosed",
+	spanner.OpenOpen:     "OpenOpen",
+}
+
+func (r CountableKeyRange) MarshalJSON() ([]byte, error) {
+	kind, ok := keyRangeKindNames[r.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown key range kind: %d", r.Kind)
+	}
+	start, err := encodeJSONKey(r.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := encodeJSONKey(r.End)
+	if err != nil {
+		return nil, err
+	}
+	var keys [][]*jsonKeyPart
+	for _, key := range r.Keys {
+		k, err := encodeJSONKey(key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return json.Marshal(&jsonKeyRange{
+		Start:              start,
+		End:                end,
+		Kind:               kind,
+		RowCount:           r.RowCount,
+		DescendantRowCount: r.DescendantRowCount,
+		MutationCount:      r.MutationCount,
+		Keys:               keys,
+	})
+}
+
+func (r *CountableKeyRange) UnmarshalJSON(data []byte) error {
+	var jr jsonKeyRange
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return err
+	}
+	kind, ok := spanner.KeyRangeKind(0), false
+	for k, name := range keyRangeKindNames {
+		if name == jr.Kind {
+			kind, ok = k, true
+		}
+	}
+	if !ok {
+		return fmt.Errorf("unknown key range kind: %q", jr.Kind)
+	}
+	start, err := decodeJSONKey(jr.Start)
+	if err != nil {
+		return err
+	}
+	end, err := decodeJSONKey(jr.End)
+	if err != nil {
+		return err
+	}
+	var keys []spanner.Key
+	for _, k := range jr.Keys {
+		key, err := decodeJSONKey(k)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	*r = CountableKeyRange{
+		KeyRange:           spanner.KeyRange{Start: start, End: end, Kind: kind},
+		RowCount:           jr.RowCount,
+		DescendantRowCount: jr.DescendantRowCount,
+		MutationCount:      jr.MutationCount,
+		Keys:               keys,
+	}
+	return nil
+}
+
+// EncodeKeyRangeToken encodes the key range to an opaque URL-safe string.
+func EncodeKeyRangeToken(r *CountableKeyRange) (string, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeKeyRangeToken decodes a string encoded by EncodeKeyRangeToken.
+func DecodeKeyRangeToken(token string) (*CountableKeyRange, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var r CountableKeyRange
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func encodeJSONKey(key spanner.Key) ([]*jsonKeyPart, error) {
+	parts := []*jsonKeyPart{}
+	for _, v := range key {
+		part, err := encodeJSONKeyPart(v)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func encodeJSONKeyPart(v interface{}) (*jsonKeyPart, error) {
+	var (
+		typ string
+		val interface{}
+	)
+	switch vv := v.(type) {
+	case bool:
+		typ, val = "BOOL", vv
+	case spanner.NullBool:
+		typ, val = "BOOL", nullOr(vv.Valid, vv.Bool)
+	case int:
+		typ, val = "INT64", strconv.FormatInt(int64(vv), 10)
+	case int8:
+		typ, val = "INT64", strconv.FormatInt(int64(vv), 10)
+	case int16:
+		typ, val = "INT64", strconv.FormatInt(int64(vv), 10)
+	case int32:
+		typ, val = "INT64", strconv.FormatInt(int64(vv), 10)
+	case int64:
+		// INT64 is encoded as a string because it can exceed the precision of JSON numbers
+		typ, val = "INT64", strconv.FormatInt(vv, 10)
+	case spanner.NullInt64:
+		typ, val = "INT64", nullOr(vv.Valid, strconv.FormatInt(vv.Int64, 10))
+	case float32:
+		typ, val = "FLOAT64", encodeJSONFloat(float64(vv))
+	case float64:
+		typ, val = "FLOAT64", encodeJSONFloat(vv)
+	case spanner.NullFloat64:
+		typ, val = "FLOAT64", nullOr(vv.Valid, encodeJSONFloat(vv.Float64))
+	case big.Rat:
+		typ, val = "NUMERIC", spanner.NumericString(&vv)
+	case spanner.NullNumeric:
+		typ, val = "NUMERIC", nullOr(vv.Valid, spanner.NumericString(&vv.Numeric))
+	case string:
+		typ, val = "STRING", vv
+	case spanner.NullString:
+		typ, val = "STRING", nullOr(vv.Valid, vv.StringVal)
+	case []byte:
+		typ, val = "BYTES", nullOr(vv != nil, base64.StdEncoding.EncodeToString(vv))
+	case civil.Date:
+		typ, val = "DATE", vv.String()
+	case spanner.NullDate:
+		typ, val = "DATE", nullOr(vv.Valid, vv.Date.String())
+	case time.Time:
+		typ, val = "TIMESTAMP", vv.UTC().Format(time.RFC3339Nano)
+	case spanner.NullTime:
+		typ, val = "TIMESTAMP", nullOr(vv.Valid, vv.Time.UTC().Format(time.RFC3339Nano))
+	default:
+		return nil, fmt.Errorf("unsupported key part type: %T", v)
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonKeyPart{Type: typ, Value: data}, nil
+}
+
+func nullOr(valid bool, v interface{}) interface{} {
+	if !valid {
+		return nil
+	}
+	return v
+}
+
+func encodeJSONFloat(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+func decodeJSONKey(parts []*jsonKeyPart) (spanner.Key, error) {
+	var key spanner.Key
+	for _, part := range parts {
+		v, err := decodeJSONKeyPart(part)
+		if err != nil {
+			return nil, err
+		}
+		key = append(key, v)
+	}
+	return key, nil
+}
+
+func decodeJSONKeyPart(part *jsonKeyPart) (interface{}, error) {
+	isNull := len(part.Value) == 0 || string(part.Value) == "null"
+	switch part.Type {
+	case "BOOL":
+		if isNull {
+			return spanner.NullBool{}, nil
+		}
+		var v bool
+		if err := json.Unmarshal(part.Value, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case "INT64":
+		if isNull {
+			return spanner.NullInt64{}, nil
+		}
+		var s string
+		if err := json.Unmarshal(part.Value, &s); err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(s, 10, 64)
+	case "FLOAT64":
+		if isNull {
+			return spanner.NullFloat64{}, nil
+		}
+		var v interface{}
+		if err := json.Unmarshal(part.Value, &v); err != nil {
+			return nil, err
+		}
+		switch vv := v.(type) {
+		case float64:
+			return vv, nil
+		case string:
+			switch vv {
+			case "NaN":
+				return math.NaN(), nil
+			case "Infinity":
+				return math.Inf(1), nil
+			case "-Infinity":
+				return math.Inf(-1), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid FLOAT64 value: %s", part.Value)
+	case "NUMERIC":
+		if isNull {
+			return spanner.NullNumeric{}, nil
+		}
+		var s string
+		if err := json.Unmarshal(part.Value, &s); err != nil {
+			return nil, err
+		}
+		v, ok := (&big.Rat{}).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid NUMERIC value: %s", part.Value)
+		}
+		return *v, nil
+	case "STRING":
+		if isNull {
+			return spanner.NullString{}, nil
+		}
+		var v string
+		if err := json.Unmarshal(part.Value, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case "BYTES":
+		if isNull {
+			return []byte(nil), nil
+		}
+		var s string
+		if err := json.Unmarshal(part.Value, &s); err != nil {
+			return nil, err
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			b = []byte{}
+		}
+		return b, nil
+	case "DATE":
+		if isNull {
+			return spanner.NullDate{}, nil
+		}
+		var s string
+		if err := json.Unmarshal(part.Value, &s); err != nil {
+			return nil, err
+		}
+		return civil.ParseDate(s)
+	case "TIMESTAMP":
+		if isNull {
+			return spanner.NullTime{}, nil
+		}
+		var s string
+		if err := json.Unmarshal(part.Value, &s); err != nil {
+			return nil, err
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	return nil, fmt.Errorf("unsupported key part type: %q", part.Type)
+}
@@ -0,0 +1,95 @@
+package spankeys_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+)
+
+func TestKeyRangeJSON(t *testing.T) {
+	ts := time.Date(2019, 12, 1, 12, 34, 56, 789000000, time.UTC)
+	r := &spankeys.CountableKeyRange{
+		KeyRange: spanner.KeyRange{
+			Start: spanner.Key{true, int64(math.MaxInt64), 1.5, "a", []byte("b"), civil.Date{Year: 2019, Month: 12, Day: 1}, ts},
+			End:   spanner.Key{spanner.NullBool{}, spanner.NullInt64{}, math.Inf(-1), spanner.NullString{}, []byte(nil), spanner.NullDate{}, spanner.NullTime{}},
+			Kind:  spanner.ClosedOpen,
+		},
+		RowCount:           100,
+		DescendantRowCount: 200,
+		MutationCount:      300,
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded spankeys.CountableKeyRange
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, r.Start, decoded.Start)
+	assert.Equal(t, r.End, decoded.End)
+	assert.Equal(t, spanner.ClosedOpen, decoded.Kind)
+	assert.Equal(t, int64(100), decoded.RowCount)
+	assert.Equal(t, int64(200), decoded.DescendantRowCount)
+	assert.Equal(t, int64(300), decoded.MutationCount)
+
+	// an INT64 keeps its precision as a string
+	assert.Contains(t, string(data), `"9223372036854775807"`)
+
+	// NaN cannot be compared by assert.Equal
+	nan := &spankeys.CountableKeyRange{KeyRange: spanner.KeyRange{Start: spanner.Key{math.NaN()}, End: spanner.Key{math.NaN()}, Kind: spanner.ClosedClosed}}
+	data, err = json.Marshal(nan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, math.IsNaN(decoded.Start[0].(float64)))
+
+	// NUMERIC keeps its precision as a string
+	num := &spankeys.CountableKeyRange{KeyRange: spanner.KeyRange{Start: spanner.Key{*big.NewRat(1, 8)}, End: spanner.Key{spanner.NullNumeric{}}, Kind: spanner.ClosedOpen}}
+	data, err = json.Marshal(num)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(data), `"0.125000000"`)
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	start := decoded.Start[0].(big.Rat)
+	assert.Equal(t, 0, start.Cmp(big.NewRat(1, 8)))
+	assert.Equal(t, spanner.NullNumeric{}, decoded.End[0])
+}
+
+func TestKeyRangeToken(t *testing.T) {
+	r := &spankeys.CountableKeyRange{
+		KeyRange: spanner.KeyRange{Start: spanner.Key{"a", int64(1)}, End: spanner.Key{"z", int64(100)}, Kind: spanner.ClosedClosed},
+		RowCount: 2,
+		Keys:     []spanner.Key{{"a", int64(1)}, {"z", int64(100)}},
+	}
+	token, err := spankeys.EncodeKeyRangeToken(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotContains(t, token, "/")
+	assert.NotContains(t, token, "+")
+
+	decoded, err := spankeys.DecodeKeyRangeToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, r, decoded)
+
+	_, err = spankeys.DecodeKeyRangeToken("invalid")
+	assert.Error(t, err)
+}
@@ -0,0 +1,395 @@
+package spankeys
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+)
+
+type ExportFormat string
+
+const (
+	// ExportFormatJSONL writes a JSON object per line.
+	ExportFormatJSONL ExportFormat = "jsonl"
+	// ExportFormatCSV writes a header line and a record per row. NULL is written as \N.
+	ExportFormatCSV ExportFormat = "csv"
+)
+
+// ExportManifestFileName is the name of the manifest written by ExportTable.
+const ExportManifestFileName = "manifest.json"
+
+// csvNull is the CSV representation of NULL.
+// A string of backslashes followed by N, such as a STRING value `\N`, is escaped by one more backslash.
+const csvNull = `\N`
+
+type ExportOptions struct {
+	// Format is the format of the files (default: ExportFormatJSONL).
+	Format ExportFormat
+
+	// Concurrency is the number of key ranges exported at the same time (default: 1).
+	Concurrency int
+
+	// RowsPerFile is the max row count of a file (default: DefaultPageSize).
+	RowsPerFile int
+
+	// PageSize is the row count fetched by one query while partitioning (default: DefaultPageSize).
+	PageSize int
+
+	// ReadTimestamp is the timestamp at which all rows are read (default: the current time).
+	ReadTimestamp time.Time
+
+	Observer Observer
+}
+
+// ExportManifest describes the files written by ExportTable.
+type ExportManifest struct {
+	Table         string          `json:"table"`
+	Format        ExportFormat    `json:"format"`
+	ReadTimestamp time.Time       `json:"readTimestamp"`
+	Columns       []*ExportColumn `json:"columns"`
+	Files         []*ExportFile   `json:"files"`
+	RowCount      int64           `json:"rowCount"`
+}
+
+type ExportColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ExportFile is a file containing the rows of a key range.
+type ExportFile struct {
+	Name     string             `json:"name"`
+	Range    *CountableKeyRange `json:"range"`
+	RowCount int64              `json:"rowCount"`
+}
+
+// ExportTable writes all rows of the table to dir at one read timestamp, one file per key range,
+// and writes the manifest listing the files in the key order.
+// Values are encoded deterministically: INT64 and NUMERIC as decimal strings, JSON as its text in a string,
+// TIMESTAMP as RFC 3339 in UTC, DATE as YYYY-MM-DD, BYTES as standard base64,
+// and ARRAY as a JSON array (also in CSV).
+func ExportTable(ctx context.Context, client *spanner.Client, tableName, dir string, opts *ExportOptions) (*ExportManifest, error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+	format := opts.Format
+	if format == "" {
+		format = ExportFormatJSONL
+	}
+	rowsPerFile := opts.RowsPerFile
+	if rowsPerFile < 1 {
+		rowsPerFile = DefaultPageSize
+	}
+	cols, err := GetColumns(ctx, client, tableName)
+	if err != nil {
+		return nil, err
+	}
+	pkCols, err := GetPrimaryKeyColumns(ctx, client, tableName)
+	if err != nil {
+		return nil, err
+	}
+	ts := opts.ReadTimestamp
+	if ts.IsZero() {
+		t, err := currentTimestamp(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		ts = t
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	manifest := &ExportManifest{Table: tableName, Format: format, ReadTimestamp: ts}
+	for _, col := range cols {
+		manifest.Columns = append(manifest.Columns, &ExportColumn{Name: col.Name, Type: col.SpannerType})
+	}
+	var mu sync.Mutex
+	it := NewKeyRangeIterator(ctx, client, tableName, pkCols, &KeyRangeOptions{
+		MutationBatchSize: rowsPerFile,
+		PageSize:          opts.PageSize,
+		TimestampBound:    spanner.ReadTimestamp(ts),
+	})
+	if _, err := RunKeyRanges(ctx, it, func(ctx context.Context, r *CountableKeyRange) error {
+		name, err := exportFileName(tableName, format, r)
+		if err != nil {
+			return err
+		}
+		iter := client.Single().WithTimestampBound(spanner.ReadTimestamp(ts)).Read(ctx, tableName, r.KeyRange, columnNames(cols))
+		n, err := exportRowsToFile(filepath.Join(dir, name), iter, format)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		manifest.Files = append(manifest.Files, &ExportFile{Name: name, Range: r, RowCount: n})
+		manifest.RowCount += n
+		mu.Unlock()
+		return nil
+	}, &RunOptions{Concurrency: opts.Concurrency, Observer: opts.Observer}); err != nil {
+		return nil, err
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return CompareKeys(manifest.Files[i].Range.Start, manifest.Files[j].Range.Start, pkCols) < 0
+	})
+	if err := writeJSONFile(filepath.Join(dir, ExportManifestFileName), manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ExportQuery writes the result of the query to w, and returns the row count.
+func ExportQuery(ctx context.Context, client *spanner.Client, stmt spanner.Statement, w io.Writer, format ExportFormat, tb spanner.TimestampBound) (int64, error) {
+	return exportRows(w, client.Single().WithTimestampBound(tb).Query(ctx, stmt), format)
+}
+
+// ReadExportManifest reads the manifest written by ExportTable in dir.
+func ReadExportManifest(dir string) (*ExportManifest, error) {
+	f, err := os.Open(filepath.Join(dir, ExportManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var m ExportManifest
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// exportFileName names the file by the hash of the key range, so that the same key range is always written to the same file.
+func exportFileName(tableName string, format ExportFormat, r *CountableKeyRange) (string, error) {
+	token, err := EncodeKeyRangeToken(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum([]byte(token))
+	return fmt.Sprintf("%s-%s.%s", tableName, hex.EncodeToString(sum[:8]), format), nil
+}
+
+func exportRowsToFile(path string, iter *spanner.RowIterator, format ExportFormat) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		iter.Stop()
+		return 0, err
+	}
+	n, err := exportRows(f, iter, format)
+	if err != nil {
+		f.Close()
+		return n, err
+	}
+	return n, f.Close()
+}
+
+func exportRows(w io.Writer, iter *spanner.RowIterator, format ExportFormat) (int64, error) {
+	defer iter.Stop()
+	bw := bufio.NewWriter(w)
+	var rw rowWriter
+	switch format {
+	case ExportFormatJSONL, "":
+		rw = &jsonlRowWriter{w: bw}
+	case ExportFormatCSV:
+		rw = &csvRowWriter{w: csv.NewWriter(bw)}
+	default:
+		return 0, fmt.Errorf("unknown export format: %q", format)
+	}
+
+	var n int64
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		values := make([]interface{}, row.Size())
+		for i := range values {
+			var gcv spanner.GenericColumnValue
+			if err := row.Column(i, &gcv); err != nil {
+				return n, err
+			}
+			var v interface{}
+			if err := DecodeToInterface(&gcv, &v); err != nil {
+				return n, fmt.Errorf("column %s: %v", row.ColumnName(i), err)
+			}
+			values[i] = exportValue(v)
+		}
+		if err := rw.writeRow(row.ColumnNames(), values); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rw.flush(); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+type rowWriter interface {
+	writeRow(columns []string, values []interface{}) error
+	flush() error
+}
+
+// jsonlRowWriter writes a JSON object per row with the keys in the column order.
+type jsonlRowWriter struct {
+	w *bufio.Writer
+}
+
+func (rw *jsonlRowWriter) writeRow(columns []string, values []interface{}) error {
+	rw.w.WriteByte('{')
+	for i, col := range columns {
+		if i > 0 {
+			rw.w.WriteByte(',')
+		}
+		k, err := json.Marshal(col)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(values[i])
+		if err != nil {
+			return err
+		}
+		rw.w.Write(k)
+		rw.w.WriteByte(':')
+		rw.w.Write(v)
+	}
+	rw.w.WriteByte('}')
+	return rw.w.WriteByte('\n')
+}
+
+func (rw *jsonlRowWriter) flush() error {
+	return nil
+}
+
+// csvRowWriter writes the header before the first row.
+type csvRowWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (rw *csvRowWriter) writeRow(columns []string, values []interface{}) error {
+	if !rw.header {
+		if err := rw.w.Write(columns); err != nil {
+			return err
+		}
+		rw.header = true
+	}
+	record := make([]string, len(values))
+	for i, v := range values {
+		s, err := csvValue(v)
+		if err != nil {
+			return err
+		}
+		record[i] = s
+	}
+	return rw.w.Write(record)
+}
+
+func (rw *csvRowWriter) flush() error {
+	rw.w.Flush()
+	return rw.w.Error()
+}
+
+// exportValue converts a value decoded by DecodeToInterface to its JSON representation.
+func exportValue(v interface{}) interface{} {
+	if isNullValue(v) {
+		return nil
+	}
+	switch vv := v.(type) {
+	case bool, string:
+		return vv
+	case int64:
+		return strconv.FormatInt(vv, 10)
+	case float64:
+		return encodeJSONFloat(vv)
+	case big.Rat:
+		// NUMERIC is exported as a string to keep its precision
+		return spanner.NumericString(&vv)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(vv)
+	case civil.Date:
+		return vv.String()
+	case time.Time:
+		return vv.UTC().Format(time.RFC3339Nano)
+	case spanner.NullBool:
+		return vv.Bool
+	case spanner.NullInt64:
+		return exportValue(vv.Int64)
+	case spanner.NullFloat64:
+		return exportValue(vv.Float64)
+	case spanner.NullNumeric:
+		return exportValue(vv.Numeric)
+	case spanner.NullString:
+		return vv.StringVal
+	case spanner.NullDate:
+		return exportValue(vv.Date)
+	case spanner.NullTime:
+		return exportValue(vv.Time)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice {
+		if rv.IsNil() {
+			return nil
+		}
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = exportValue(rv.Index(i).Interface())
+		}
+		return list
+	}
+	return v
+}
+
+func csvValue(v interface{}) (string, error) {
+	switch vv := v.(type) {
+	case nil:
+		return csvNull, nil
+	case string:
+		if isCSVNullLike(vv) {
+			return `\` + vv, nil
+		}
+		return vv, nil
+	case bool:
+		return strconv.FormatBool(vv), nil
+	case float64:
+		return strconv.FormatFloat(vv, 'g', -1, 64), nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// isCSVNullLike reports whether s is one or more backslashes followed by N.
+func isCSVNullLike(s string) bool {
+	return len(s) >= 2 && strings.HasSuffix(s, "N") && strings.Trim(s[:len(s)-1], `\`) == ""
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,117 @@
+package spankeys_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+	"github.com/castaneai/spankeys/testutils"
+)
+
+func TestExportTable(t *testing.T) {
+	tableName := "ExportTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+    Name STRING(255),
+    Data BYTES(MAX),
+    Tags ARRAY<STRING(MAX)>,
+    Day DATE,
+    CreatedAt TIMESTAMP,
+) PRIMARY KEY (ID)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createdAt := time.Date(2020, 1, 2, 3, 4, 5, 6, time.FixedZone("JST", 9*60*60))
+	var ms []*spanner.Mutation
+	for i := 0; i < 250; i++ {
+		ms = append(ms, spanner.Insert(tableName, []string{"ID", "Name", "Data", "Tags", "Day", "CreatedAt"}, []interface{}{
+			int64(i), fmt.Sprintf("name%d", i), []byte("data"), []string{"a", "b"}, civil.Date{Year: 2020, Month: 1, Day: 2}, createdAt,
+		}))
+	}
+	ms = append(ms, spanner.Insert(tableName, []string{"ID"}, []interface{}{int64(250)}))
+	if _, err := c.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "spankeys-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest, err := spankeys.ExportTable(ctx, c, tableName, dir, &spankeys.ExportOptions{Concurrency: 2, RowsPerFile: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(251), manifest.RowCount)
+	assert.Equal(t, 3, len(manifest.Files))
+	assert.Equal(t, "Tags", manifest.Columns[3].Name)
+	assert.Equal(t, "ARRAY<STRING(MAX)>", manifest.Columns[3].Type)
+
+	read, err := spankeys.ReadExportManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(manifest.Files), len(read.Files))
+
+	f, err := os.Open(filepath.Join(dir, manifest.Files[0].Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	assert.True(t, sc.Scan())
+	assert.Equal(t, `{"ID":"0","Name":"name0","Data":"ZGF0YQ==","Tags":["a","b"],"Day":"2020-01-02","CreatedAt":"2020-01-01T18:04:05.000000006Z"}`, sc.Text())
+
+	var buf bytes.Buffer
+	n, err := spankeys.ExportQuery(ctx, c, spanner.NewStatement(fmt.Sprintf("SELECT ID, Name, Tags FROM %s WHERE ID >= 249 ORDER BY ID", tableName)), &buf, spankeys.ExportFormatCSV, spanner.StrongRead())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, "ID,Name,Tags\n249,name249,\"[\"\"a\"\",\"\"b\"\"]\"\n250,\\N,\\N\n", buf.String())
+
+	var row map[string]interface{}
+	buf.Reset()
+	if _, err := spankeys.ExportQuery(ctx, c, spanner.NewStatement(fmt.Sprintf("SELECT ID, Name FROM %s WHERE ID = 250", tableName)), &buf, spankeys.ExportFormatJSONL, spanner.StrongRead()); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &row); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{"ID": "250", "Name": nil}, row)
+
+	buf.Reset()
+	stmt := spanner.NewStatement(`SELECT NUMERIC "1.5" AS N, CAST(NULL AS NUMERIC) AS NN, JSON '{"a":1}' AS J, [NUMERIC "2"] AS NA`)
+	if _, err := spankeys.ExportQuery(ctx, c, stmt, &buf, spankeys.ExportFormatJSONL, spanner.StrongRead()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `{"N":"1.500000000","NN":null,"J":"{\"a\":1}","NA":["2.000000000"]}`+"\n", buf.String())
+
+	buf.Reset()
+	if _, err := spankeys.ExportQuery(ctx, c, stmt, &buf, spankeys.ExportFormatCSV, spanner.StrongRead()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "N,NN,J,NA\n1.500000000,\\N,\"{\"\"a\"\":1}\",\"[\"\"2.000000000\"\"]\"\n", buf.String())
+}
@@ -3,13 +3,13 @@ module github.com/castaneai/spankeys
 go 1.12
 
 require (
-	cloud.google.com/go v0.49.0
-	cloud.google.com/go/spanner v1.1.0
+	cloud.google.com/go v0.97.0
+	cloud.google.com/go/spanner v1.25.0
 	github.com/castaneai/spadmin v0.1.0
-	github.com/golang/protobuf v1.3.2
-	github.com/google/uuid v1.1.1
-	github.com/stretchr/testify v1.4.0
-	google.golang.org/api v0.14.0
-	google.golang.org/genproto v0.0.0-20191206224255-0243a4be9c8f
-	google.golang.org/grpc v1.25.1
+	github.com/golang/protobuf v1.5.2
+	github.com/google/uuid v1.1.2
+	github.com/stretchr/testify v1.6.1
+	google.golang.org/api v0.60.0
+	google.golang.org/genproto v0.0.0-20211118181313-81c1377c94b1
+	google.golang.org/grpc v1.40.0
 )
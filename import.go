@@ -0,0 +1,629 @@
+package spankeys
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+)
+
+type ImportOptions struct {
+	// Format is the format of the input (default: ExportFormatJSONL).
+	Format ExportFormat
+
+	// BatchSize is the max row count written by one commit (default: result of CalcBatchSize).
+	BatchSize int
+
+	// Rejects receives the original lines of the bad rows instead of aborting the import.
+	// The rows rejected by Cloud Spanner (e.g. NOT NULL or the max length) are found by retrying the failed commit row by row.
+	// The errors of the rows are returned by ImportReport.Rejected.
+	Rejects io.Writer
+}
+
+type ImportReport struct {
+	RowCount int64
+	Rejected []*ImportError
+}
+
+// ImportError is an error of a row in the input.
+type ImportError struct {
+	File   string
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *ImportError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: column %s: %v", e.File, e.Line, e.Column, e.Err)
+}
+
+// ImportFile writes the rows in the JSONL or CSV file to the table by InsertOrUpdate mutations.
+// Each value is coerced to the type of the column in INFORMATION_SCHEMA.COLUMNS, in the encoding written by ExportTable.
+func ImportFile(ctx context.Context, client *spanner.Client, tableName, path string, opts *ImportOptions) (*ImportReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ImportReader(ctx, client, tableName, path, f, opts)
+}
+
+// ImportReader is the same as ImportFile, but reads the rows from r. name is used in the errors.
+func ImportReader(ctx context.Context, client *spanner.Client, tableName, name string, r io.Reader, opts *ImportOptions) (*ImportReport, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	cols, err := GetColumns(ctx, client, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) < 1 {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+	columns := make(map[string]*Column, len(cols))
+	for _, col := range cols {
+		columns[col.Name] = col
+	}
+	batchSize := opts.BatchSize
+	if batchSize < 1 {
+		bs, err := CalcBatchSize(ctx, client, tableName, MutationInsertOrUpdate)
+		if err != nil {
+			return nil, err
+		}
+		batchSize = bs
+	}
+
+	var rr recordReader
+	switch opts.Format {
+	case ExportFormatJSONL, "":
+		rr = &jsonlRecordReader{r: bufio.NewReader(r)}
+	case ExportFormatCSV:
+		rr = newCSVRecordReader(r)
+	default:
+		return nil, fmt.Errorf("unknown import format: %q", opts.Format)
+	}
+
+	report := &ImportReport{}
+	reject := func(rec *importRecord, ie *ImportError) error {
+		if opts.Rejects == nil {
+			return ie
+		}
+		if err := rr.reject(opts.Rejects, rec); err != nil {
+			return err
+		}
+		report.Rejected = append(report.Rejected, ie)
+		return nil
+	}
+	batch := &mutationBatch{maxRows: batchSize}
+	var records []*importRecord
+	flush := func() error {
+		defer func() {
+			batch.reset()
+			records = nil
+		}()
+		err := applyMutations(ctx, client, batch.mutations, batch.mutationCount)
+		if err == nil {
+			report.RowCount += int64(len(batch.mutations))
+			return nil
+		}
+		if !isRowError(err) {
+			return err
+		}
+		// retries the rows one by one, so that only the bad rows are rejected
+		for i, m := range batch.mutations {
+			if err := applyMutations(ctx, client, []*spanner.Mutation{m}, int64(len(records[i].columns))); err != nil {
+				if !isRowError(err) {
+					return err
+				}
+				if err := reject(records[i], &ImportError{File: name, Line: records[i].line, Err: err}); err != nil {
+					return err
+				}
+				continue
+			}
+			report.RowCount++
+		}
+		return nil
+	}
+	for {
+		rec, err := rr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, &ImportError{File: name, Line: rr.line(), Err: err}
+		}
+		m, size, err := importMutation(tableName, columns, rec)
+		if err != nil {
+			ie := &ImportError{File: name, Line: rec.line, Err: err}
+			if ce, ok := err.(*importColumnError); ok {
+				ie.Column, ie.Err = ce.column, ce.err
+			}
+			if err := reject(rec, ie); err != nil {
+				return report, err
+			}
+			continue
+		}
+		if !batch.fits(size) {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+		batch.add(m, size, len(rec.columns))
+		records = append(records, rec)
+		if batch.full() {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	if len(batch.mutations) > 0 {
+		if err := flush(); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// isRowError reports whether the commit failed by the values of a row (e.g. NOT NULL, the max length or a foreign key),
+// not by the availability of the database. NotFound is not a row error, because a missing table or column fails every row.
+func isRowError(err error) bool {
+	switch spanner.ErrCode(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange, codes.AlreadyExists:
+		return true
+	}
+	return false
+}
+
+// importRecord is a row in the input. Each value is nil, bool, json.Number, string or []interface{} in JSONL,
+// and a string in CSV. raw is the text of the row, which is written to the rejects as is.
+type importRecord struct {
+	line    int
+	columns []string
+	values  []interface{}
+	raw     []byte
+	err     error
+}
+
+type importColumnError struct {
+	column string
+	err    error
+}
+
+func (e *importColumnError) Error() string {
+	return fmt.Sprintf("column %s: %v", e.column, e.err)
+}
+
+// importMutation returns the mutation and the byte size of its values.
+// It ignores the values of the generated columns, which are also written by ExportTable.
+func importMutation(tableName string, columns map[string]*Column, rec *importRecord) (*spanner.Mutation, int64, error) {
+	if rec.err != nil {
+		return nil, 0, rec.err
+	}
+	var names []string
+	var values []interface{}
+	for i, name := range rec.columns {
+		col, ok := columns[name]
+		if !ok {
+			return nil, 0, &importColumnError{column: name, err: errors.New("unknown column")}
+		}
+		if col.IsGenerated() {
+			continue
+		}
+		v, err := coerceValue(parsedColumnType(col), rec.values[i])
+		if err != nil {
+			return nil, 0, &importColumnError{column: name, err: err}
+		}
+		names = append(names, name)
+		values = append(values, v)
+	}
+	return spanner.InsertOrUpdate(tableName, names, values), valuesSize(values), nil
+}
+
+type recordReader interface {
+	next() (*importRecord, error)
+	line() int
+	reject(w io.Writer, rec *importRecord) error
+}
+
+type jsonlRecordReader struct {
+	r      *bufio.Reader
+	lineNo int
+}
+
+func (rr *jsonlRecordReader) next() (*importRecord, error) {
+	for {
+		data, err := rr.r.ReadBytes('\n')
+		if err == io.EOF && len(data) == 0 {
+			return nil, io.EOF
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		rr.lineNo++
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		rec := &importRecord{line: rr.lineNo, raw: data}
+		// a malformed row is returned with the error so that it can be rejected
+		rec.err = decodeJSONObject(data, rec)
+		return rec, nil
+	}
+}
+
+// decodeJSONObject decodes the JSON object keeping the order of the keys.
+func decodeJSONObject(data []byte, rec *importRecord) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return errors.New("a row must be a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		rec.columns = append(rec.columns, tok.(string))
+		rec.values = append(rec.values, v)
+	}
+	_, err := dec.Token()
+	return err
+}
+
+func (rr *jsonlRecordReader) line() int {
+	return rr.lineNo
+}
+
+func (rr *jsonlRecordReader) reject(w io.Writer, rec *importRecord) error {
+	return writeRawLines(w, rec.raw)
+}
+
+func writeRawLines(w io.Writer, raw []byte) error {
+	if !bytes.HasSuffix(raw, []byte("\n")) {
+		raw = append(raw, '\n')
+	}
+	_, err := w.Write(raw)
+	return err
+}
+
+// csvRecordReader reads the header as the column names. \N is read as NULL.
+type csvRecordReader struct {
+	r         *csv.Reader
+	lr        *csvLineReader
+	header    []string
+	headerRaw []byte
+	lineNo    int
+	rejected  bool
+}
+
+func newCSVRecordReader(r io.Reader) *csvRecordReader {
+	lr := &csvLineReader{r: bufio.NewReader(r)}
+	return &csvRecordReader{r: csv.NewReader(lr), lr: lr}
+}
+
+// read reads a record and returns the text of its lines and the line number where it starts.
+func (rr *csvRecordReader) read() ([]string, []byte, int, error) {
+	rr.lr.raw = nil
+	fields, err := rr.r.Read()
+	raw := rr.lr.raw
+	start := rr.lineNo + 1
+	rr.lineNo += bytes.Count(raw, []byte("\n"))
+	// csv.Reader skips empty lines before a record
+	for len(raw) > 0 && (raw[0] == '\n' || bytes.HasPrefix(raw, []byte("\r\n"))) {
+		raw = raw[bytes.IndexByte(raw, '\n')+1:]
+		start++
+	}
+	return fields, raw, start, err
+}
+
+func (rr *csvRecordReader) next() (*importRecord, error) {
+	if rr.header == nil {
+		header, raw, _, err := rr.read()
+		if err != nil {
+			return nil, err
+		}
+		rr.header, rr.headerRaw = header, raw
+	}
+	fields, raw, line, err := rr.read()
+	if _, ok := err.(*csv.ParseError); ok {
+		// a malformed row is returned with the error so that it can be rejected
+		return &importRecord{line: line, columns: rr.header, raw: raw, err: err}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec := &importRecord{line: line, columns: rr.header, raw: raw}
+	for _, f := range fields {
+		switch {
+		case f == csvNull:
+			rec.values = append(rec.values, nil)
+		case isCSVNullLike(f):
+			// unescapes the string escaped by ExportTable
+			rec.values = append(rec.values, f[1:])
+		default:
+			rec.values = append(rec.values, f)
+		}
+	}
+	return rec, nil
+}
+
+func (rr *csvRecordReader) line() int {
+	return rr.lineNo
+}
+
+func (rr *csvRecordReader) reject(w io.Writer, rec *importRecord) error {
+	if !rr.rejected {
+		if err := writeRawLines(w, rr.headerRaw); err != nil {
+			return err
+		}
+		rr.rejected = true
+	}
+	return writeRawLines(w, rec.raw)
+}
+
+// csvLineReader returns at most one line by a Read and keeps the lines read,
+// so that the text of a record is known although csv.Reader buffers its input.
+type csvLineReader struct {
+	r       *bufio.Reader
+	pending []byte
+	err     error
+	raw     []byte
+}
+
+func (lr *csvLineReader) Read(p []byte) (int, error) {
+	if len(lr.pending) == 0 {
+		if lr.err != nil {
+			return 0, lr.err
+		}
+		lr.pending, lr.err = lr.r.ReadBytes('\n')
+		if len(lr.pending) == 0 {
+			return 0, lr.err
+		}
+	}
+	n := copy(p, lr.pending)
+	lr.raw = append(lr.raw, lr.pending[:n]...)
+	lr.pending = lr.pending[n:]
+	return n, nil
+}
+
+// coerceValue converts v to the Go type of the column type. NULL is converted to the Null type of the column type.
+func coerceValue(t *SpannerType, v interface{}) (interface{}, error) {
+	if t == nil {
+		return nil, errors.New("unsupported type")
+	}
+	if t.Code == TypeArray {
+		return coerceArray(t.ElementType, v)
+	}
+	return coerceScalar(t, v)
+}
+
+func coerceScalar(t *SpannerType, v interface{}) (interface{}, error) {
+	s, isString := v.(string)
+	if n, ok := v.(json.Number); ok {
+		s, isString = n.String(), t.Code == TypeInt64 || t.Code == TypeFloat64 || t.Code == TypeNumeric
+	}
+	switch t.Code {
+	case TypeBool:
+		if v == nil {
+			return spanner.NullBool{}, nil
+		}
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		if isString {
+			return strconv.ParseBool(s)
+		}
+	case TypeInt64:
+		if v == nil {
+			return spanner.NullInt64{}, nil
+		}
+		if isString {
+			return strconv.ParseInt(s, 10, 64)
+		}
+	case TypeFloat64:
+		if v == nil {
+			return spanner.NullFloat64{}, nil
+		}
+		if isString {
+			return strconv.ParseFloat(s, 64)
+		}
+	case TypeNumeric:
+		if v == nil {
+			return spanner.NullNumeric{}, nil
+		}
+		if isString {
+			if r, ok := new(big.Rat).SetString(s); ok {
+				return *r, nil
+			}
+		}
+	case TypeString:
+		if v == nil {
+			return spanner.NullString{}, nil
+		}
+		if isString {
+			return s, nil
+		}
+	case TypeJSON:
+		if v == nil {
+			return spanner.NullJSON{}, nil
+		}
+		// JSON is the text of the value in a string, as written by ExportTable
+		if isString && json.Valid([]byte(s)) {
+			return spanner.NullJSON{Value: json.RawMessage(s), Valid: true}, nil
+		}
+	case TypeBytes:
+		if v == nil {
+			return []byte(nil), nil
+		}
+		if isString {
+			return base64.StdEncoding.DecodeString(s)
+		}
+	case TypeDate:
+		if v == nil {
+			return spanner.NullDate{}, nil
+		}
+		if isString {
+			return civil.ParseDate(s)
+		}
+	case TypeTimestamp:
+		if v == nil {
+			return spanner.NullTime{}, nil
+		}
+		if isString {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type: %s", t)
+	}
+	return nil, fmt.Errorf("cannot convert %v to %s", v, t)
+}
+
+func coerceArray(elemType *SpannerType, v interface{}) (interface{}, error) {
+	// an array is a JSON array also in CSV
+	if s, ok := v.(string); ok {
+		dec := json.NewDecoder(strings.NewReader(s))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+	}
+	var list []interface{}
+	if v != nil {
+		l, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %v to ARRAY<%s>", v, elemType)
+		}
+		list = l
+	}
+	elems := make([]interface{}, len(list))
+	for i, e := range list {
+		ev, err := coerceScalar(elemType, e)
+		if err != nil {
+			return nil, fmt.Errorf("array element %d: %v", i, err)
+		}
+		elems[i] = ev
+	}
+	return typedArray(elemType, elems, v == nil)
+}
+
+// typedArray converts the coerced elements to a slice of the Null type, which can contain NULL elements.
+func typedArray(elemType *SpannerType, elems []interface{}, isNull bool) (interface{}, error) {
+	switch elemType.Code {
+	case TypeBool:
+		if isNull {
+			return []spanner.NullBool(nil), nil
+		}
+		vs := make([]spanner.NullBool, len(elems))
+		for i, e := range elems {
+			if b, ok := e.(bool); ok {
+				vs[i] = spanner.NullBool{Bool: b, Valid: true}
+			}
+		}
+		return vs, nil
+	case TypeInt64:
+		if isNull {
+			return []spanner.NullInt64(nil), nil
+		}
+		vs := make([]spanner.NullInt64, len(elems))
+		for i, e := range elems {
+			if n, ok := e.(int64); ok {
+				vs[i] = spanner.NullInt64{Int64: n, Valid: true}
+			}
+		}
+		return vs, nil
+	case TypeFloat64:
+		if isNull {
+			return []spanner.NullFloat64(nil), nil
+		}
+		vs := make([]spanner.NullFloat64, len(elems))
+		for i, e := range elems {
+			if f, ok := e.(float64); ok {
+				vs[i] = spanner.NullFloat64{Float64: f, Valid: true}
+			}
+		}
+		return vs, nil
+	case TypeNumeric:
+		if isNull {
+			return []spanner.NullNumeric(nil), nil
+		}
+		vs := make([]spanner.NullNumeric, len(elems))
+		for i, e := range elems {
+			if r, ok := e.(big.Rat); ok {
+				vs[i] = spanner.NullNumeric{Numeric: r, Valid: true}
+			}
+		}
+		return vs, nil
+	case TypeString:
+		if isNull {
+			return []spanner.NullString(nil), nil
+		}
+		vs := make([]spanner.NullString, len(elems))
+		for i, e := range elems {
+			if s, ok := e.(string); ok {
+				vs[i] = spanner.NullString{StringVal: s, Valid: true}
+			}
+		}
+		return vs, nil
+	case TypeJSON:
+		if isNull {
+			return []spanner.NullJSON(nil), nil
+		}
+		vs := make([]spanner.NullJSON, len(elems))
+		for i, e := range elems {
+			vs[i], _ = e.(spanner.NullJSON)
+		}
+		return vs, nil
+	case TypeBytes:
+		if isNull {
+			return [][]byte(nil), nil
+		}
+		vs := make([][]byte, len(elems))
+		for i, e := range elems {
+			vs[i], _ = e.([]byte)
+		}
+		return vs, nil
+	case TypeDate:
+		if isNull {
+			return []spanner.NullDate(nil), nil
+		}
+		vs := make([]spanner.NullDate, len(elems))
+		for i, e := range elems {
+			if d, ok := e.(civil.Date); ok {
+				vs[i] = spanner.NullDate{Date: d, Valid: true}
+			}
+		}
+		return vs, nil
+	case TypeTimestamp:
+		if isNull {
+			return []spanner.NullTime(nil), nil
+		}
+		vs := make([]spanner.NullTime, len(elems))
+		for i, e := range elems {
+			if t, ok := e.(time.Time); ok {
+				vs[i] = spanner.NullTime{Time: t, Valid: true}
+			}
+		}
+		return vs, nil
+	}
+	return nil, fmt.Errorf("unsupported type: ARRAY<%s>", elemType)
+}
@@ -0,0 +1,218 @@
+package spankeys_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+	"github.com/castaneai/spankeys/testutils"
+)
+
+func TestImportReader(t *testing.T) {
+	tableName := "ImportTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+    Name STRING(255),
+    Score FLOAT64,
+    Data BYTES(MAX),
+    Tags ARRAY<STRING(MAX)>,
+    Day DATE,
+    CreatedAt TIMESTAMP,
+) PRIMARY KEY (ID)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("JSONL", func(t *testing.T) {
+		input := strings.Join([]string{
+			`{"ID":"1","Name":"name1","Score":1.5,"Data":"ZGF0YQ==","Tags":["a",null],"Day":"2020-01-02","CreatedAt":"2020-01-02T03:04:05.000000006Z"}`,
+			`{"ID":2,"Name":null,"Score":"NaN","Tags":null}`,
+			`{"ID":"3","Day":"not a date"}`,
+			`{"ID":"4",`,
+		}, "\n")
+		var rejects bytes.Buffer
+		report, err := spankeys.ImportReader(ctx, c, tableName, "input.jsonl", strings.NewReader(input), &spankeys.ImportOptions{Rejects: &rejects})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, int64(2), report.RowCount)
+		assert.Equal(t, 2, len(report.Rejected))
+		assert.Equal(t, 3, report.Rejected[0].Line)
+		assert.Equal(t, "Day", report.Rejected[0].Column)
+		assert.Equal(t, 4, report.Rejected[1].Line)
+		assert.Equal(t, `{"ID":"3","Day":"not a date"}`+"\n"+`{"ID":"4",`+"\n", rejects.String())
+
+		cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s where ID = 1 and Name = 'name1' and Data = b'data' and ARRAY_LENGTH(Tags) = 2 and Day = '2020-01-02'", tableName), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, int64(1), cnt)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		input := "ID,Name,Tags\n10,\"multi\nline\",\"[\"\"x\"\"]\"\n11,\\N,\\N\nbad,name,\\N\n"
+		_, err := spankeys.ImportReader(ctx, c, tableName, "input.csv", strings.NewReader(input), &spankeys.ImportOptions{Format: spankeys.ExportFormatCSV})
+		ie, ok := err.(*spankeys.ImportError)
+		if !assert.True(t, ok) {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "input.csv", ie.File)
+		assert.Equal(t, 5, ie.Line)
+		assert.Equal(t, "ID", ie.Column)
+
+		cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s where ID in (10, 11)", tableName), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the rows before the bad row are not written because they are in the same batch
+		assert.Equal(t, int64(0), cnt)
+	})
+
+	t.Run("Rejects", func(t *testing.T) {
+		input := "ID,Name\n20,ok\n21,too,many\n22," + strings.Repeat("x", 256) + "\n24,a\"b\n23,ok\n"
+		var rejects bytes.Buffer
+		report, err := spankeys.ImportReader(ctx, c, tableName, "input.csv", strings.NewReader(input), &spankeys.ImportOptions{
+			Format:  spankeys.ExportFormatCSV,
+			Rejects: &rejects,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the row rejected by Cloud Spanner does not stop the other rows in the same batch
+		assert.Equal(t, int64(2), report.RowCount)
+		assert.Equal(t, 3, len(report.Rejected))
+		assert.Equal(t, 3, report.Rejected[0].Line)
+		assert.Equal(t, 5, report.Rejected[1].Line)
+		assert.Equal(t, 4, report.Rejected[2].Line)
+		// the rejects keep the original lines, also of the rows which cannot be parsed
+		assert.Equal(t, "ID,Name\n21,too,many\n24,a\"b\n22,"+strings.Repeat("x", 256)+"\n", rejects.String())
+
+		cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s where ID in (20, 23)", tableName), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, int64(2), cnt)
+	})
+}
+
+func TestExportImportCSVNull(t *testing.T) {
+	srcTable, dstTable := "CSVNullSrc", "CSVNullDst"
+
+	ctx := context.Background()
+	var ddls []string
+	for _, table := range []string{srcTable, dstTable} {
+		ddls = append(ddls, fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+    Name STRING(MAX),
+) PRIMARY KEY (ID)
+`, table))
+	}
+	if err := testutils.PrepareDatabase(ctx, ddls); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []spanner.NullString{
+		{},
+		{StringVal: `\N`, Valid: true},
+		{StringVal: `\\N`, Valid: true},
+		{StringVal: `N`, Valid: true},
+	}
+	var ms []*spanner.Mutation
+	for i, name := range names {
+		ms = append(ms, spanner.Insert(srcTable, []string{"ID", "Name"}, []interface{}{int64(i), name}))
+	}
+	if _, err := c.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := spankeys.ExportQuery(ctx, c, spanner.NewStatement(fmt.Sprintf("SELECT ID, Name FROM %s ORDER BY ID", srcTable)), &buf, spankeys.ExportFormatCSV, spanner.StrongRead()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "ID,Name\n0,\\N\n1,\\\\N\n2,\\\\\\N\n3,N\n", buf.String())
+	if _, err := spankeys.ImportReader(ctx, c, dstTable, "input.csv", &buf, &spankeys.ImportOptions{Format: spankeys.ExportFormatCSV}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, name := range names {
+		row, err := c.Single().ReadRow(ctx, dstTable, spanner.Key{int64(i)}, []string{"Name"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got spanner.NullString
+		if err := row.Column(0, &got); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, name, got)
+	}
+}
+
+func TestExportImportNumericJSON(t *testing.T) {
+	srcTable, dstTable := "NumericJSONSrc", "NumericJSONDst"
+
+	ctx := context.Background()
+	var ddls []string
+	for _, table := range []string{srcTable, dstTable} {
+		ddls = append(ddls, fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+    Price NUMERIC,
+    Prices ARRAY<NUMERIC>,
+    Attrs JSON,
+) PRIMARY KEY (ID)
+`, table))
+	}
+	if err := testutils.PrepareDatabase(ctx, ddls); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Apply(ctx, []*spanner.Mutation{
+		spanner.Insert(srcTable, []string{"ID", "Price", "Prices", "Attrs"}, []interface{}{
+			int64(1), *big.NewRat(12345, 1000), []spanner.NullNumeric{{Numeric: *big.NewRat(1, 1), Valid: true}, {}}, spanner.NullJSON{Value: map[string]interface{}{"a": []int{1, 2}}, Valid: true},
+		}),
+		spanner.Insert(srcTable, []string{"ID"}, []interface{}{int64(2)}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []spankeys.ExportFormat{spankeys.ExportFormatJSONL, spankeys.ExportFormatCSV} {
+		var buf bytes.Buffer
+		if _, err := spankeys.ExportQuery(ctx, c, spanner.NewStatement(fmt.Sprintf("SELECT * FROM %s ORDER BY ID", srcTable)), &buf, format, spanner.StrongRead()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := spankeys.ImportReader(ctx, c, dstTable, "input", &buf, &spankeys.ImportOptions{Format: format}); err != nil {
+			t.Fatal(err)
+		}
+
+		cnt, err := testutils.CountsRow(ctx, fmt.Sprintf(`select count(*) from %s s join %s d using (ID)
+where s.Price is not distinct from d.Price and TO_JSON_STRING(s.Prices) = TO_JSON_STRING(d.Prices) and TO_JSON_STRING(s.Attrs) = TO_JSON_STRING(d.Attrs)`, srcTable, dstTable), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, int64(2), cnt, format)
+	}
+}
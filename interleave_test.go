@@ -0,0 +1,61 @@
+package spankeys_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+)
+
+func TestBuildInterleaveTree(t *testing.T) {
+	tables := []*spankeys.Table{
+		{Name: "GrandChild", Interleave: &spankeys.Interleave{Table: "ChildB"}},
+		{Name: "ChildB", Interleave: &spankeys.Interleave{Table: "Parent"}},
+		{Name: "ChildA", Interleave: &spankeys.Interleave{Table: "Parent"}},
+		{Name: "Parent"},
+		{Name: "Other"},
+	}
+	roots, err := spankeys.BuildInterleaveTree(tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(roots))
+	assert.Equal(t, "Other", roots[0].Name)
+	assert.Equal(t, "Parent", roots[1].Name)
+
+	var names []string
+	for _, table := range roots[1].Tables() {
+		names = append(names, table.Name)
+	}
+	assert.Equal(t, []string{"Parent", "ChildA", "ChildB", "GrandChild"}, names)
+
+	_, err = spankeys.BuildInterleaveTree([]*spankeys.Table{{Name: "Orphan", Interleave: &spankeys.Interleave{Table: "Missing"}}})
+	assert.Error(t, err)
+}
+
+func TestSortTablesByDependency(t *testing.T) {
+	tables := []*spankeys.Table{
+		{Name: "Child", Interleave: &spankeys.Interleave{Table: "Parent"}},
+		{Name: "Parent"},
+		{Name: "Order"},
+		{Name: "Customer"},
+	}
+	fks := []*spankeys.ForeignKey{
+		{Name: "FK_OrderCustomer", Table: "Order", Columns: []string{"CustomerID"}, ReferencedTable: "Customer", ReferencedColumns: []string{"CustomerID"}},
+		{Name: "FK_ParentOrder", Table: "Parent", Columns: []string{"OrderID"}, ReferencedTable: "Order", ReferencedColumns: []string{"OrderID"}},
+	}
+	sorted, err := spankeys.SortTablesByDependency(tables, fks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, table := range sorted {
+		names = append(names, table.Name)
+	}
+	assert.Equal(t, []string{"Customer", "Order", "Parent", "Child"}, names)
+
+	fks = append(fks, &spankeys.ForeignKey{Name: "FK_CustomerChild", Table: "Customer", ReferencedTable: "Child"})
+	_, err = spankeys.SortTablesByDependency(tables, fks)
+	assert.Error(t, err)
+}
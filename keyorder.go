@@ -0,0 +1,177 @@
+package spankeys
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+)
+
+// CompareKeys compares two keys in the key order of Cloud Spanner and returns -1, 0 or +1.
+// NULL is the smallest value, and the order of a key part is reversed if its column is DESC.
+// If cols is shorter than the keys, the rest of the key parts are in ascending order.
+// If one key is a prefix of the other, the shorter key is smaller.
+func CompareKeys(a, b spanner.Key, cols []*Column) int {
+	if c := compareKeyPrefix(a, b, cols); c != 0 {
+		return c
+	}
+	return compareInt(int64(len(a)), int64(len(b)))
+}
+
+// compareKeyPrefix compares only the common prefix of two keys.
+func compareKeyPrefix(a, b spanner.Key, cols []*Column) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		c := compareKeyPart(a[i], b[i])
+		if i < len(cols) && cols[i].IsDesc() {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareKeyPart(a, b interface{}) int {
+	an, bn := isNullValue(a), isNullValue(b)
+	switch {
+	case an && bn:
+		return 0
+	case an:
+		return -1
+	case bn:
+		return 1
+	}
+	a, b = normalizeKeyPart(a), normalizeKeyPart(b)
+	switch av := a.(type) {
+	case bool:
+		if bv, ok := b.(bool); ok {
+			return compareInt(boolToInt(av), boolToInt(bv))
+		}
+	case int64:
+		if bv, ok := b.(int64); ok {
+			return compareInt(av, bv)
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return compareFloat(av, bv)
+		}
+	case big.Rat:
+		if bv, ok := b.(big.Rat); ok {
+			return av.Cmp(&bv)
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			// Go compares strings byte-wise, as Cloud Spanner compares UTF-8 bytes
+			return stringCompare(av, bv)
+		}
+	case []byte:
+		if bv, ok := b.([]byte); ok {
+			return bytes.Compare(av, bv)
+		}
+	case civil.Date:
+		if bv, ok := b.(civil.Date); ok {
+			switch {
+			case av.Before(bv):
+				return -1
+			case bv.Before(av):
+				return 1
+			}
+			return 0
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			switch {
+			case av.Before(bv):
+				return -1
+			case av.After(bv):
+				return 1
+			}
+			return 0
+		}
+	}
+	// keys of a column never have different types, but compares them deterministically anyway
+	return stringCompare(fmt.Sprintf("%T", a), fmt.Sprintf("%T", b))
+}
+
+// normalizeKeyPart converts a non-NULL key part to one of bool, int64, float64, big.Rat, string, []byte, civil.Date and time.Time.
+func normalizeKeyPart(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case int:
+		return int64(vv)
+	case int8:
+		return int64(vv)
+	case int16:
+		return int64(vv)
+	case int32:
+		return int64(vv)
+	case float32:
+		return float64(vv)
+	case spanner.NullBool:
+		return vv.Bool
+	case spanner.NullInt64:
+		return vv.Int64
+	case spanner.NullFloat64:
+		return vv.Float64
+	case *big.Rat:
+		return *vv
+	case spanner.NullNumeric:
+		return vv.Numeric
+	case spanner.NullString:
+		return vv.StringVal
+	case spanner.NullDate:
+		return vv.Date
+	case spanner.NullTime:
+		return vv.Time
+	}
+	return v
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareFloat orders NaN before any other values as Cloud Spanner does.
+func compareFloat(a, b float64) int {
+	an, bn := math.IsNaN(a), math.IsNaN(b)
+	switch {
+	case an && bn:
+		return 0
+	case an:
+		return -1
+	case bn:
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func stringCompare(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
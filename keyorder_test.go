@@ -0,0 +1,112 @@
+package spankeys_test
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+)
+
+func TestCompareKeys(t *testing.T) {
+	asc := []*spankeys.Column{{Name: "A", Ordering: spankeys.ColumnOrderingAsc}}
+	desc := []*spankeys.Column{{Name: "A", Ordering: spankeys.ColumnOrderingDesc}}
+	ts := time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		a, b spanner.Key
+		want int
+	}{
+		{spanner.Key{false}, spanner.Key{true}, -1},
+		{spanner.Key{int64(1)}, spanner.Key{int64(2)}, -1},
+		{spanner.Key{2}, spanner.Key{int64(2)}, 0},
+		{spanner.Key{math.NaN()}, spanner.Key{math.Inf(-1)}, -1},
+		{spanner.Key{1.5}, spanner.Key{-1.5}, 1},
+		{spanner.Key{*big.NewRat(1, 3)}, spanner.Key{*big.NewRat(1, 2)}, -1},
+		{spanner.Key{spanner.NullNumeric{Numeric: *big.NewRat(5, 1), Valid: true}}, spanner.Key{*big.NewRat(5, 1)}, 0},
+		{spanner.Key{spanner.NullNumeric{}}, spanner.Key{*big.NewRat(-1, 1)}, -1},
+		{spanner.Key{"a"}, spanner.Key{"b"}, -1},
+		{spanner.Key{"B"}, spanner.Key{"a"}, -1},
+		{spanner.Key{[]byte{0x01}}, spanner.Key{[]byte{0x01, 0x00}}, -1},
+		{spanner.Key{civil.Date{Year: 2019, Month: 12, Day: 2}}, spanner.Key{civil.Date{Year: 2019, Month: 12, Day: 1}}, 1},
+		{spanner.Key{ts}, spanner.Key{ts.Add(time.Nanosecond)}, -1},
+		{spanner.Key{spanner.NullInt64{}}, spanner.Key{int64(math.MinInt64)}, -1},
+		{spanner.Key{spanner.NullString{}}, spanner.Key{spanner.NullString{}}, 0},
+		{spanner.Key{spanner.NullInt64{Int64: 3, Valid: true}}, spanner.Key{int64(3)}, 0},
+		{spanner.Key{[]byte(nil)}, spanner.Key{[]byte{}}, -1},
+		{spanner.Key{"a"}, spanner.Key{"a", int64(1)}, -1},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, spankeys.CompareKeys(c.a, c.b, asc), "%v <=> %v", c.a, c.b)
+		assert.Equal(t, -c.want, spankeys.CompareKeys(c.b, c.a, asc), "%v <=> %v", c.b, c.a)
+		// the prefix order does not depend on DESC
+		if len(c.a) == len(c.b) {
+			assert.Equal(t, -c.want, spankeys.CompareKeys(c.a, c.b, desc), "%v <=> %v (DESC)", c.a, c.b)
+		}
+	}
+
+	// composite key with the second part in DESC
+	cols := []*spankeys.Column{{Name: "A"}, {Name: "B", Ordering: spankeys.ColumnOrderingDesc}}
+	assert.Equal(t, -1, spankeys.CompareKeys(spanner.Key{"a", int64(2)}, spanner.Key{"a", int64(1)}, cols))
+	assert.Equal(t, -1, spankeys.CompareKeys(spanner.Key{"a", int64(1)}, spanner.Key{"b", int64(2)}, cols))
+	assert.Equal(t, -1, spankeys.CompareKeys(spanner.Key{"a", int64(1)}, spanner.Key{"a", spanner.NullInt64{}}, cols))
+}
+
+func TestKeyRangeAlgebra(t *testing.T) {
+	cols := []*spankeys.Column{{Name: "A"}, {Name: "B"}}
+	r := spanner.KeyRange{Start: spanner.Key{int64(10)}, End: spanner.Key{int64(20)}, Kind: spanner.ClosedClosed}
+
+	// contains
+	assert.True(t, spankeys.KeyRangeContains(r, spanner.Key{int64(10), int64(1)}, cols))
+	assert.True(t, spankeys.KeyRangeContains(r, spanner.Key{int64(20), int64(100)}, cols))
+	assert.False(t, spankeys.KeyRangeContains(r, spanner.Key{int64(21), int64(0)}, cols))
+	open := spanner.KeyRange{Start: spanner.Key{int64(10)}, End: spanner.Key{int64(20)}, Kind: spanner.OpenOpen}
+	assert.False(t, spankeys.KeyRangeContains(open, spanner.Key{int64(10), int64(1)}, cols))
+	assert.False(t, spankeys.KeyRangeContains(open, spanner.Key{int64(20), int64(1)}, cols))
+	assert.True(t, spankeys.KeyRangeContains(open, spanner.Key{int64(11), int64(1)}, cols))
+
+	// empty
+	assert.False(t, spankeys.IsEmptyKeyRange(spanner.KeyRange{Start: spanner.Key{int64(1)}, End: spanner.Key{int64(1)}, Kind: spanner.ClosedClosed}, cols))
+	assert.True(t, spankeys.IsEmptyKeyRange(spanner.KeyRange{Start: spanner.Key{int64(1)}, End: spanner.Key{int64(1)}, Kind: spanner.ClosedOpen}, cols))
+	assert.True(t, spankeys.IsEmptyKeyRange(spanner.KeyRange{Start: spanner.Key{int64(2)}, End: spanner.Key{int64(1)}, Kind: spanner.ClosedClosed}, cols))
+
+	// intersect
+	other := spanner.KeyRange{Start: spanner.Key{int64(15)}, End: spanner.Key{int64(30)}, Kind: spanner.OpenOpen}
+	is, ok := spankeys.IntersectKeyRanges(r, other, cols)
+	assert.True(t, ok)
+	assert.Equal(t, spanner.KeyRange{Start: spanner.Key{int64(15)}, End: spanner.Key{int64(20)}, Kind: spanner.OpenClosed}, is)
+	_, ok = spankeys.IntersectKeyRanges(r, spanner.KeyRange{Start: spanner.Key{int64(20)}, End: spanner.Key{int64(30)}, Kind: spanner.OpenClosed}, cols)
+	assert.False(t, ok)
+
+	// split
+	left, right, ok := spankeys.SplitKeyRange(r, spanner.Key{int64(15), int64(0)}, cols)
+	assert.True(t, ok)
+	assert.Equal(t, spanner.KeyRange{Start: spanner.Key{int64(10)}, End: spanner.Key{int64(15), int64(0)}, Kind: spanner.ClosedOpen}, left)
+	assert.Equal(t, spanner.KeyRange{Start: spanner.Key{int64(15), int64(0)}, End: spanner.Key{int64(20)}, Kind: spanner.ClosedClosed}, right)
+	assert.True(t, spankeys.KeyRangeContains(right, spanner.Key{int64(15), int64(0)}, cols))
+	assert.False(t, spankeys.KeyRangeContains(left, spanner.Key{int64(15), int64(0)}, cols))
+	_, _, ok = spankeys.SplitKeyRange(r, spanner.Key{int64(30)}, cols)
+	assert.False(t, ok)
+
+	// merge
+	a := &spankeys.CountableKeyRange{KeyRange: spanner.KeyRange{Start: spanner.Key{int64(1)}, End: spanner.Key{int64(5)}, Kind: spanner.ClosedClosed}, RowCount: 5}
+	b := &spankeys.CountableKeyRange{KeyRange: spanner.KeyRange{Start: spanner.Key{int64(6)}, End: spanner.Key{int64(9)}, Kind: spanner.ClosedClosed}, RowCount: 4}
+	merged, err := spankeys.MergeKeyRanges(a, b, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, spanner.KeyRange{Start: spanner.Key{int64(1)}, End: spanner.Key{int64(9)}, Kind: spanner.ClosedClosed}, merged.KeyRange)
+	assert.Equal(t, int64(9), merged.RowCount)
+	_, err = spankeys.MergeKeyRanges(b, a, cols)
+	assert.Error(t, err)
+
+	// validate
+	assert.NoError(t, spankeys.ValidateKeyRanges([]*spankeys.CountableKeyRange{a, b}, cols))
+	assert.Error(t, spankeys.ValidateKeyRanges([]*spankeys.CountableKeyRange{b, a}, cols))
+	assert.Error(t, spankeys.ValidateKeyRanges([]*spankeys.CountableKeyRange{a, merged}, cols))
+}
@@ -0,0 +1,127 @@
+package spankeys
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+)
+
+// keyBound is a position between keys.
+// A key of a bound can be a prefix of row keys:
+// if after is false, the position is just before all keys which start with the key,
+// otherwise it is just after them.
+type keyBound struct {
+	key   spanner.Key
+	after bool
+}
+
+func startBound(r spanner.KeyRange) keyBound {
+	return keyBound{key: r.Start, after: r.Kind == spanner.OpenClosed || r.Kind == spanner.OpenOpen}
+}
+
+func endBound(r spanner.KeyRange) keyBound {
+	return keyBound{key: r.End, after: r.Kind == spanner.ClosedClosed || r.Kind == spanner.OpenClosed}
+}
+
+func compareBounds(a, b keyBound, cols []*Column) int {
+	if c := compareKeyPrefix(a.key, b.key, cols); c != 0 {
+		return c
+	}
+	if a.after != b.after {
+		if a.after {
+			return 1
+		}
+		return -1
+	}
+	// a shorter prefix covers more keys
+	c := compareInt(int64(len(a.key)), int64(len(b.key)))
+	if a.after {
+		return -c
+	}
+	return c
+}
+
+func keyRangeFromBounds(start, end keyBound) spanner.KeyRange {
+	var kind spanner.KeyRangeKind
+	switch {
+	case !start.after && end.after:
+		kind = spanner.ClosedClosed
+	case !start.after && !end.after:
+		kind = spanner.ClosedOpen
+	case start.after && end.after:
+		kind = spanner.OpenClosed
+	default:
+		kind = spanner.OpenOpen
+	}
+	return spanner.KeyRange{Start: start.key, End: end.key, Kind: kind}
+}
+
+// IsEmptyKeyRange reports whether the key range can contain no key.
+func IsEmptyKeyRange(r spanner.KeyRange, cols []*Column) bool {
+	return compareBounds(startBound(r), endBound(r), cols) >= 0
+}
+
+// KeyRangeContains reports whether the key range contains the row key.
+func KeyRangeContains(r spanner.KeyRange, key spanner.Key, cols []*Column) bool {
+	point := keyBound{key: key}
+	if compareBounds(startBound(r), point, cols) > 0 {
+		return false
+	}
+	return compareBounds(point, endBound(r), cols) < 0
+}
+
+// IntersectKeyRanges returns the intersection of two key ranges.
+// The second return value is false if they do not intersect.
+func IntersectKeyRanges(a, b spanner.KeyRange, cols []*Column) (spanner.KeyRange, bool) {
+	start := startBound(a)
+	if s := startBound(b); compareBounds(s, start, cols) > 0 {
+		start = s
+	}
+	end := endBound(a)
+	if e := endBound(b); compareBounds(e, end, cols) < 0 {
+		end = e
+	}
+	if compareBounds(start, end, cols) >= 0 {
+		return spanner.KeyRange{}, false
+	}
+	return keyRangeFromBounds(start, end), true
+}
+
+// SplitKeyRange splits the key range into the keys before the key and the rest.
+// The third return value is false if the key is at the start of the range or outside it, so one side would be empty.
+func SplitKeyRange(r spanner.KeyRange, at spanner.Key, cols []*Column) (spanner.KeyRange, spanner.KeyRange, bool) {
+	point := keyBound{key: at}
+	start, end := startBound(r), endBound(r)
+	if compareBounds(start, point, cols) >= 0 || compareBounds(point, end, cols) >= 0 {
+		return spanner.KeyRange{}, spanner.KeyRange{}, false
+	}
+	return keyRangeFromBounds(start, point), keyRangeFromBounds(point, end), true
+}
+
+// MergeKeyRanges merges two consecutive key ranges into one key range.
+// The key range a must be before b without overlapping, and the keys between them are included in the merged range.
+// This is for key ranges returned by partitioning, where no rows exist between consecutive ranges.
+func MergeKeyRanges(a, b *CountableKeyRange, cols []*Column) (*CountableKeyRange, error) {
+	if compareBounds(endBound(a.KeyRange), startBound(b.KeyRange), cols) > 0 {
+		return nil, fmt.Errorf("key range %s is not before %s", a.KeyRange.String(), b.KeyRange.String())
+	}
+	return &CountableKeyRange{
+		KeyRange:           keyRangeFromBounds(startBound(a.KeyRange), endBound(b.KeyRange)),
+		RowCount:           a.RowCount + b.RowCount,
+		DescendantRowCount: a.DescendantRowCount + b.DescendantRowCount,
+		MutationCount:      a.MutationCount + b.MutationCount,
+	}, nil
+}
+
+// ValidateKeyRanges checks that each key range is not empty, and that the key ranges are sorted without overlapping.
+func ValidateKeyRanges(ranges []*CountableKeyRange, cols []*Column) error {
+	for i, r := range ranges {
+		if IsEmptyKeyRange(r.KeyRange, cols) {
+			return fmt.Errorf("key range[%d] %s is empty", i, r.KeyRange.String())
+		}
+		if i > 0 && compareBounds(endBound(ranges[i-1].KeyRange), startBound(r.KeyRange), cols) > 0 {
+			return fmt.Errorf("key range[%d] %s overlaps with the previous key range %s", i, r.KeyRange.String(), ranges[i-1].KeyRange.String())
+		}
+	}
+	return nil
+}
@@ -6,13 +6,27 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	DefaultPageSize = 10000
 )
 
 type CountableKeyRange struct {
 	spanner.KeyRange
 	RowCount int64
+
+	// DescendantRowCount and MutationCount are set only by the cascade partitioning.
+	// See NewCascadeKeyRangeIterator.
+	DescendantRowCount int64
+	MutationCount      int64
+
+	// Keys are the keys of all rows in the key range, set only if KeyRangeOptions.CollectKeys is true.
+	Keys []spanner.Key
 }
 
 func CountIndexesWithChildren(ctx context.Context, client *spanner.Client, tableName string) (int, error) {
@@ -47,60 +61,362 @@ func CalcMutationBatchSize(ctx context.Context, client *spanner.Client, tableNam
 		// if the table has no index, mutation count is 1 regardless of the number of rows
 		return math.MaxInt32, nil
 	}
-	return 20000/idxCnt - 1, nil
+	return MaxMutationsPerCommit/idxCnt - 1, nil
 }
 
 func PartitionsKeyRanges(ctx context.Context, client *spanner.Client, tableName string, pkColumns []*Column, mutationBatchSize, selectLimit int) ([]*CountableKeyRange, error) {
+	it := NewKeyRangeIterator(ctx, client, tableName, pkColumns, &KeyRangeOptions{
+		MutationBatchSize: mutationBatchSize,
+		PageSize:          selectLimit,
+		MaxRows:           selectLimit,
+	})
+	return collectKeyRanges(it)
+}
+
+// PartitionsAllKeyRanges partitions all rows of the table regardless of its size.
+// Rows are fetched by pages of pageSize rows ordered by the primary key,
+// and each page resumes after the last key of the previous page.
+func PartitionsAllKeyRanges(ctx context.Context, client *spanner.Client, tableName string, pkColumns []*Column, mutationBatchSize, pageSize int) ([]*CountableKeyRange, error) {
+	it := NewKeyRangeIterator(ctx, client, tableName, pkColumns, &KeyRangeOptions{
+		MutationBatchSize: mutationBatchSize,
+		PageSize:          pageSize,
+	})
+	return collectKeyRanges(it)
+}
+
+func collectKeyRanges(it *KeyRangeIterator) ([]*CountableKeyRange, error) {
+	var keySets []*CountableKeyRange
+	if err := it.Do(func(r *CountableKeyRange) error {
+		keySets = append(keySets, r)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return keySets, nil
+}
+
+type KeyRangeOptions struct {
+	// MutationBatchSize is the max row count of a key range.
+	MutationBatchSize int
+
+	// PageSize is the row count fetched by one query (default: DefaultPageSize).
+	PageSize int
+
+	// MaxRows stops scanning after MaxRows rows. If zero, the whole table is scanned.
+	MaxRows int
+
+	// Where is a predicate to filter rows (e.g. "CreatedAt < @cutoff") with its parameters.
+	// Key ranges cover only the matching rows, and RowCount is the count of the matching rows.
+	// Note that a key range can also contain non-matching rows between the matching rows.
+	Where       string
+	WhereParams map[string]interface{}
+
+	// CollectKeys sets the keys of all rows to CountableKeyRange.Keys,
+	// so that only the matching rows can be processed by the keys.
+	CollectKeys bool
+
+	// Observer receives each key range closed by the scan by OnRangePartitioned.
+	Observer Observer
+
+	// TimestampBound is the staleness of the scan (default: strong read).
+	TimestampBound spanner.TimestampBound
+}
+
+// KeyRangeIterator scans a table in the key order and yields each key range as soon as it is closed.
+type KeyRangeIterator struct {
+	ctx       context.Context
+	client    *spanner.Client
+	tableName string
+	pkColumns []*Column
+	opts      KeyRangeOptions
+
+	rows      *spanner.RowIterator
+	pageLimit int
+	pageRows  int
+	scanned   int
+	lastKey   spanner.Key
+	builder   *keyRangeBuilder
+	cascade   *cascadeCost
+	startedAt time.Time
+	done      bool
+	err       error
+}
+
+func NewKeyRangeIterator(ctx context.Context, client *spanner.Client, tableName string, pkColumns []*Column, opts *KeyRangeOptions) *KeyRangeIterator {
+	it := &KeyRangeIterator{
+		ctx:       ctx,
+		client:    client,
+		tableName: tableName,
+		pkColumns: pkColumns,
+	}
+	if opts != nil {
+		it.opts = *opts
+	}
+	if it.opts.PageSize < 1 {
+		it.opts.PageSize = DefaultPageSize
+	}
+	it.builder = &keyRangeBuilder{mutationBatchSize: it.opts.MutationBatchSize, collectKeys: it.opts.CollectKeys}
+	if len(pkColumns) < 1 {
+		it.err = errors.New("at least one of Primary Key is required")
+	}
+	return it
+}
+
+// Next returns the next key range. Its second return value is iterator.Done if there are no more key ranges.
+func (it *KeyRangeIterator) Next() (*CountableKeyRange, error) {
+	for {
+		if len(it.builder.keySets) > 0 {
+			r := it.builder.keySets[0]
+			it.builder.keySets = it.builder.keySets[1:]
+			if it.opts.Observer != nil {
+				it.opts.Observer.OnRangePartitioned(r, time.Since(it.startedAt))
+			}
+			it.startedAt = time.Now()
+			return r, nil
+		}
+		if it.err != nil {
+			return nil, it.err
+		}
+		if it.done {
+			return nil, iterator.Done
+		}
+		if it.startedAt.IsZero() {
+			it.startedAt = time.Now()
+		}
+		if it.rows == nil {
+			if it.opts.MaxRows > 0 && it.scanned >= it.opts.MaxRows {
+				it.finish()
+				continue
+			}
+			it.pageLimit = it.opts.PageSize
+			if it.opts.MaxRows > 0 && it.opts.MaxRows-it.scanned < it.pageLimit {
+				it.pageLimit = it.opts.MaxRows - it.scanned
+			}
+			it.pageRows = 0
+			var selects []string
+			if it.cascade != nil {
+				selects = it.cascade.selects(it.pkColumns)
+			}
+			stmt := buildPageStatement(it.tableName, it.pkColumns, selects, it.opts.Where, it.opts.WhereParams, it.lastKey, it.pageLimit)
+			it.rows = it.client.Single().WithTimestampBound(it.opts.TimestampBound).Query(it.ctx, stmt)
+		}
+		row, err := it.rows.Next()
+		if err == iterator.Done {
+			it.rows.Stop()
+			it.rows = nil
+			if it.pageRows < it.pageLimit {
+				it.finish()
+			}
+			continue
+		}
+		if err != nil {
+			return nil, it.fail(err)
+		}
+		key, err := decodeKey(row, it.pkColumns)
+		if err != nil {
+			return nil, it.fail(err)
+		}
+		if it.cascade != nil {
+			mutations, descendants, err := it.cascade.rowCost(row)
+			if err != nil {
+				return nil, it.fail(err)
+			}
+			it.builder.addWithCost(key, mutations, descendants)
+		} else {
+			it.builder.add(key)
+		}
+		it.lastKey = key
+		it.pageRows++
+		it.scanned++
+	}
+}
+
+// Do calls the provided function once in sequence for each key range.
+// If the function returns a non-nil error, Do immediately returns that error.
+func (it *KeyRangeIterator) Do(f func(r *CountableKeyRange) error) error {
+	defer it.Stop()
+	for {
+		r, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := f(r); err != nil {
+			return err
+		}
+	}
+}
+
+// Stop terminates the iteration. It should be called after you finish using the iterator.
+func (it *KeyRangeIterator) Stop() {
+	if it.rows != nil {
+		it.rows.Stop()
+		it.rows = nil
+	}
+}
+
+func (it *KeyRangeIterator) fail(err error) error {
+	it.err = err
+	it.Stop()
+	if it.opts.Observer != nil {
+		it.opts.Observer.OnError(nil, err)
+	}
+	return err
+}
+
+// resumeAfter makes the iterator scan after the key. It must be called before the first Next.
+func (it *KeyRangeIterator) resumeAfter(key spanner.Key) {
+	it.lastKey = key
+}
+
+func (it *KeyRangeIterator) finish() {
+	it.builder.flush()
+	it.done = true
+}
+
+// buildPageStatement builds a query which selects the next pageSize keys matching the filter after the lastKey.
+// If the lastKey is nil, the query selects from the first key of the table.
+// The table can be referred as `t` in the additional select expressions.
+func buildPageStatement(tableName string, pkColumns []*Column, selects []string, filter string, filterParams map[string]interface{}, lastKey spanner.Key, pageSize int) spanner.Statement {
 	var pkns []string
 	for _, col := range pkColumns {
 		pkns = append(pkns, fmt.Sprintf("`%s`", col.Name))
 	}
-	if len(pkns) < 1 {
-		return nil, errors.New("at least one of Primary Key is required")
+	selectList := append(append([]string{}, pkns...), selects...)
+	params := make(map[string]interface{})
+	for k, v := range filterParams {
+		params[k] = v
 	}
-	sql := fmt.Sprintf("SELECT %s FROM `%s` ORDER BY %s ASC LIMIT %d", strings.Join(pkns, ","), tableName, pkns[0], selectLimit)
-	stmt := spanner.NewStatement(sql)
-
-	var keySets []*CountableKeyRange
-	var startKey spanner.Key
-	var currentKey spanner.Key
-	cnt := 0
-	if err := client.Single().Query(ctx, stmt).Do(func(r *spanner.Row) error {
-		var key spanner.Key
-		for _, col := range pkColumns {
-			var gcv spanner.GenericColumnValue
-			if err := r.ColumnByName(col.Name, &gcv); err != nil {
-				return err
-			}
-			var k interface{}
-			if err := DecodeToInterface(&gcv, &k); err != nil {
-				return err
+	var conds []string
+	if filter != "" {
+		conds = append(conds, fmt.Sprintf("(%s)", filter))
+	}
+	if lastKey != nil {
+		// (k0 > @k0) OR (k0 = @k0 AND k1 > @k1) OR ... ("<" for DESC key parts)
+		var ors []string
+		for i := range lastKey {
+			var ands []string
+			for j := 0; j < i; j++ {
+				ands = append(ands, keyPartEqual(pkns[j], fmt.Sprintf("spankeysLastKey%d", j), lastKey[j], params))
 			}
-			key = append(key, k)
+			ands = append(ands, keyPartAfter(pkns[i], fmt.Sprintf("spankeysLastKey%d", i), lastKey[i], pkColumns[i].IsDesc(), params))
+			ors = append(ors, fmt.Sprintf("(%s)", strings.Join(ands, " AND ")))
 		}
-		currentKey = key
-		if cnt == 0 {
-			startKey = key
+		conds = append(conds, fmt.Sprintf("(%s)", strings.Join(ors, " OR ")))
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = fmt.Sprintf(" WHERE %s", strings.Join(conds, " AND "))
+	}
+	sql := fmt.Sprintf("SELECT %s FROM `%s` AS t%s ORDER BY %s LIMIT %d", strings.Join(selectList, ","), tableName, where, keyOrderBy(pkColumns), pageSize)
+	return spanner.Statement{SQL: sql, Params: params}
+}
+
+// keyOrderBy returns ORDER BY expressions which follow the key order of the table.
+func keyOrderBy(pkColumns []*Column) string {
+	var orders []string
+	for _, col := range pkColumns {
+		if col.IsDesc() {
+			orders = append(orders, fmt.Sprintf("`%s` DESC", col.Name))
+		} else {
+			orders = append(orders, fmt.Sprintf("`%s` ASC", col.Name))
 		}
-		cnt++
-		if cnt >= mutationBatchSize {
-			endKey := key
-			keySets = append(keySets, &CountableKeyRange{
-				KeyRange: spanner.KeyRange{Start: startKey, End: endKey, Kind: spanner.ClosedClosed},
-				RowCount: int64(cnt),
-			})
-			cnt = 0
+	}
+	return strings.Join(orders, ",")
+}
+
+func keyPartEqual(column, param string, v interface{}, params map[string]interface{}) string {
+	if isNullValue(v) {
+		return fmt.Sprintf("%s IS NULL", column)
+	}
+	params[param] = v
+	return fmt.Sprintf("%s = @%s", column, param)
+}
+
+// keyPartAfter returns a condition that the column comes after v in the key order.
+// NULL is the first value in ascending order and the last value in descending order.
+func keyPartAfter(column, param string, v interface{}, desc bool, params map[string]interface{}) string {
+	if isNullValue(v) {
+		if desc {
+			return "FALSE"
 		}
-		return nil
-	}); err != nil {
-		return nil, err
+		return fmt.Sprintf("%s IS NOT NULL", column)
+	}
+	params[param] = v
+	if desc {
+		return fmt.Sprintf("(%s < @%s OR %s IS NULL)", column, param, column)
+	}
+	return fmt.Sprintf("%s > @%s", column, param)
+}
+
+func decodeKey(r *spanner.Row, pkColumns []*Column) (spanner.Key, error) {
+	var key spanner.Key
+	for _, col := range pkColumns {
+		var gcv spanner.GenericColumnValue
+		if err := r.ColumnByName(col.Name, &gcv); err != nil {
+			return nil, err
+		}
+		var k interface{}
+		if err := DecodeToInterface(&gcv, &k); err != nil {
+			return nil, err
+		}
+		key = append(key, k)
 	}
-	if cnt > 0 && currentKey != nil {
-		keySets = append(keySets, &CountableKeyRange{
-			KeyRange: spanner.KeyRange{Start: startKey, End: currentKey, Kind: spanner.ClosedClosed},
-			RowCount: int64(cnt),
+	return key, nil
+}
+
+// keyRangeBuilder closes a key range every mutationBatchSize keys,
+// or before the mutation count exceeds maxMutations if it is set.
+type keyRangeBuilder struct {
+	mutationBatchSize int
+	maxMutations      int64
+	collectKeys       bool
+	keys              []spanner.Key
+	keySets           []*CountableKeyRange
+	startKey          spanner.Key
+	currentKey        spanner.Key
+	cnt               int
+	mutations         int64
+	descendants       int64
+}
+
+func (b *keyRangeBuilder) add(key spanner.Key) {
+	b.addWithCost(key, 0, 0)
+}
+
+func (b *keyRangeBuilder) addWithCost(key spanner.Key, mutations, descendants int64) {
+	if b.cnt > 0 && b.maxMutations > 0 && b.mutations+mutations > b.maxMutations {
+		b.flush()
+	}
+	b.currentKey = key
+	if b.cnt == 0 {
+		b.startKey = key
+	}
+	if b.collectKeys {
+		b.keys = append(b.keys, key)
+	}
+	b.cnt++
+	b.mutations += mutations
+	b.descendants += descendants
+	if b.cnt >= b.mutationBatchSize || (b.maxMutations > 0 && b.mutations >= b.maxMutations) {
+		b.flush()
+	}
+}
+
+func (b *keyRangeBuilder) flush() {
+	if b.cnt > 0 && b.currentKey != nil {
+		b.keySets = append(b.keySets, &CountableKeyRange{
+			KeyRange:           spanner.KeyRange{Start: b.startKey, End: b.currentKey, Kind: spanner.ClosedClosed},
+			RowCount:           int64(b.cnt),
+			DescendantRowCount: b.descendants,
+			MutationCount:      b.mutations,
+			Keys:               b.keys,
 		})
-		cnt = 0
+		b.keys = nil
+		b.cnt = 0
+		b.mutations = 0
+		b.descendants = 0
 	}
-	return keySets, nil
 }
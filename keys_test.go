@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/castaneai/spankeys"
 
 	"github.com/stretchr/testify/assert"
 
+	"google.golang.org/api/iterator"
+
 	"github.com/google/uuid"
 
 	"cloud.google.com/go/spanner"
@@ -282,3 +285,255 @@ CREATE INDEX %s_Name ON %s(Name)
 		assert.Equal(t, int64(0), cnt)
 	}
 }
+
+func TestPartitionsAllKeyRanges(t *testing.T) {
+	tableName := "PagedPartitioningTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID1 STRING(36) NOT NULL,
+    ID2 INT64 NOT NULL,
+    Name STRING(255) NOT NULL,
+) PRIMARY KEY (ID1, ID2)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := c.ReadWriteTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+			var ms []*spanner.Mutation
+			for i := 0; i < 1000; i++ {
+				id1 := uuid.Must(uuid.NewRandom()).String()
+				for j := 0; j < 5; j++ {
+					name := uuid.Must(uuid.NewRandom()).String()
+					ms = append(ms, spanner.Insert(tableName, []string{"ID1", "ID2", "Name"}, []interface{}{id1, int64(j), name}))
+				}
+			}
+			return tx.BufferWrite(ms)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkCols, err := spankeys.GetPrimaryKeyColumns(ctx, c, tableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the page size is not a multiple of the row count of each ID1
+	keysets, err := spankeys.PartitionsAllKeyRanges(ctx, c, tableName, pkCols, 10000, 3001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(keysets))
+	var total int64
+	for _, ks := range keysets {
+		total += ks.RowCount
+	}
+	assert.Equal(t, int64(5*5000), total)
+
+	// delete all rows by partitioned keysets
+	for _, ks := range keysets {
+		if _, err := c.Apply(ctx, []*spanner.Mutation{spanner.Delete(tableName, ks)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	{
+		cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s", tableName), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, int64(0), cnt)
+	}
+}
+
+func TestPartitionsDescKeyRanges(t *testing.T) {
+	tableName := "DescPartitioningTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+    Seq INT64 NOT NULL,
+    Name STRING(255) NOT NULL,
+) PRIMARY KEY (ID DESC, Seq)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ms []*spanner.Mutation
+	for i := 0; i < 1000; i++ {
+		for j := 0; j < 3; j++ {
+			name := uuid.Must(uuid.NewRandom()).String()
+			ms = append(ms, spanner.Insert(tableName, []string{"ID", "Seq", "Name"}, []interface{}{int64(i), int64(j), name}))
+		}
+	}
+	if _, err := c.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	pkCols, err := spankeys.GetPrimaryKeyColumns(ctx, c, tableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keysets, err := spankeys.PartitionsAllKeyRanges(ctx, c, tableName, pkCols, 1000, 700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, len(keysets))
+	// ranges follow the key order, so the first range starts with the largest ID
+	assert.Equal(t, int64(999), keysets[0].Start[0])
+	assert.Equal(t, int64(0), keysets[2].End[0])
+	assert.NoError(t, spankeys.ValidateKeyRanges(keysets, pkCols))
+
+	// every range is valid for spanner.Read
+	for _, ks := range keysets {
+		var cnt int64
+		if err := c.Single().Read(ctx, tableName, ks, []string{"ID"}).Do(func(r *spanner.Row) error {
+			cnt++
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, ks.RowCount, cnt)
+	}
+}
+
+func TestKeyRangeIterator(t *testing.T) {
+	tableName := "KeyRangeIteratorTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID STRING(36) NOT NULL,
+    Name STRING(255) NOT NULL,
+) PRIMARY KEY (ID)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ms []*spanner.Mutation
+	for i := 0; i < 5000; i++ {
+		id := uuid.Must(uuid.NewRandom()).String()
+		name := uuid.Must(uuid.NewRandom()).String()
+		ms = append(ms, spanner.Insert(tableName, []string{"ID", "Name"}, []interface{}{id, name}))
+	}
+	if _, err := c.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	pkCols, err := spankeys.GetPrimaryKeyColumns(ctx, c, tableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := spankeys.NewKeyRangeIterator(ctx, c, tableName, pkCols, &spankeys.KeyRangeOptions{
+		MutationBatchSize: 1000,
+		PageSize:          1500,
+	})
+	defer it.Stop()
+
+	var total int64
+	ranges := 0
+	for {
+		ks, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// delete the range while the iterator is still scanning
+		if _, err := c.Apply(ctx, []*spanner.Mutation{spanner.Delete(tableName, ks)}); err != nil {
+			t.Fatal(err)
+		}
+		total += ks.RowCount
+		ranges++
+	}
+	assert.Equal(t, 5, ranges)
+	assert.Equal(t, int64(5000), total)
+
+	cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s", tableName), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(0), cnt)
+}
+
+func TestPartitionsFilteredKeyRanges(t *testing.T) {
+	tableName := "FilteredPartitioningTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+    CreatedAt TIMESTAMP NOT NULL,
+) PRIMARY KEY (ID)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cutoff := time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC)
+	var ms []*spanner.Mutation
+	for i := 0; i < 3000; i++ {
+		createdAt := cutoff.Add(time.Hour)
+		// one third of the rows are old
+		if i%3 == 0 {
+			createdAt = cutoff.Add(-time.Hour)
+		}
+		ms = append(ms, spanner.Insert(tableName, []string{"ID", "CreatedAt"}, []interface{}{int64(i), createdAt}))
+	}
+	if _, err := c.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	pkCols, err := spankeys.GetPrimaryKeyColumns(ctx, c, tableName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := spankeys.NewKeyRangeIterator(ctx, c, tableName, pkCols, &spankeys.KeyRangeOptions{
+		MutationBatchSize: 300,
+		PageSize:          500,
+		Where:             "CreatedAt < @cutoff",
+		WhereParams:       map[string]interface{}{"cutoff": cutoff},
+	})
+	var keysets []*spankeys.CountableKeyRange
+	if err := it.Do(func(r *spankeys.CountableKeyRange) error {
+		keysets = append(keysets, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, len(keysets))
+	var total int64
+	for _, ks := range keysets {
+		total += ks.RowCount
+	}
+	assert.Equal(t, int64(1000), total)
+	assert.Equal(t, int64(0), keysets[0].Start[0])
+	assert.Equal(t, int64(2997), keysets[3].End[0])
+}
@@ -0,0 +1,118 @@
+package spankeys
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// BatchPartition is a partition of a read or a query by BatchReadOnlyTransaction.
+// It can be serialized and executed on a separate worker,
+// and all partitions returned by one call read at the same timestamp.
+type BatchPartition struct {
+	TransactionID spanner.BatchReadOnlyTransactionID
+	Partition     *spanner.Partition
+	ReadTimestamp time.Time
+}
+
+type encodedBatchPartition struct {
+	TransactionID []byte
+	Partition     []byte
+	ReadTimestamp time.Time
+}
+
+// PartitionsRead partitions a read of all rows of the table.
+// Unlike PartitionsKeyRanges, the partitions are computed by Cloud Spanner without scanning every key.
+func PartitionsRead(ctx context.Context, client *spanner.Client, tableName string, columns []string, tb spanner.TimestampBound, opt spanner.PartitionOptions) ([]*BatchPartition, error) {
+	return partitions(ctx, client, tb, func(txn *spanner.BatchReadOnlyTransaction) ([]*spanner.Partition, error) {
+		return txn.PartitionRead(ctx, tableName, spanner.AllKeys(), columns, opt)
+	})
+}
+
+// PartitionsQuery partitions a query. The query must be root-partitionable.
+// https://cloud.google.com/spanner/docs/reads#read_data_in_parallel
+func PartitionsQuery(ctx context.Context, client *spanner.Client, stmt spanner.Statement, tb spanner.TimestampBound, opt spanner.PartitionOptions) ([]*BatchPartition, error) {
+	return partitions(ctx, client, tb, func(txn *spanner.BatchReadOnlyTransaction) ([]*spanner.Partition, error) {
+		return txn.PartitionQuery(ctx, stmt, opt)
+	})
+}
+
+func partitions(ctx context.Context, client *spanner.Client, tb spanner.TimestampBound, partition func(txn *spanner.BatchReadOnlyTransaction) ([]*spanner.Partition, error)) ([]*BatchPartition, error) {
+	txn, err := client.BatchReadOnlyTransaction(ctx, tb)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := partition(txn)
+	if err != nil {
+		txn.Cleanup(ctx)
+		return nil, err
+	}
+	ts, err := txn.Timestamp()
+	if err != nil {
+		txn.Cleanup(ctx)
+		return nil, err
+	}
+	// the transaction must stay alive for workers, so only closes it in this process
+	txn.Close()
+
+	var bps []*BatchPartition
+	for _, p := range ps {
+		bps = append(bps, &BatchPartition{
+			TransactionID: txn.ID,
+			Partition:     p,
+			ReadTimestamp: ts,
+		})
+	}
+	return bps, nil
+}
+
+// Execute runs the partition. The client can be different from the one which partitioned.
+func (p *BatchPartition) Execute(ctx context.Context, client *spanner.Client) *spanner.RowIterator {
+	txn := client.BatchReadOnlyTransactionFromID(p.TransactionID)
+	return txn.Execute(ctx, p.Partition)
+}
+
+// Cleanup releases the transaction shared by all partitions of the same call.
+// It should be called once after all partitions are executed.
+func (p *BatchPartition) Cleanup(ctx context.Context, client *spanner.Client) {
+	client.BatchReadOnlyTransactionFromID(p.TransactionID).Cleanup(ctx)
+}
+
+func (p *BatchPartition) MarshalBinary() ([]byte, error) {
+	tid, err := p.TransactionID.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	pt, err := p.Partition.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&encodedBatchPartition{
+		TransactionID: tid,
+		Partition:     pt,
+		ReadTimestamp: p.ReadTimestamp,
+	}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *BatchPartition) UnmarshalBinary(data []byte) error {
+	var ebp encodedBatchPartition
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&ebp); err != nil {
+		return err
+	}
+	if err := p.TransactionID.UnmarshalBinary(ebp.TransactionID); err != nil {
+		return err
+	}
+	p.Partition = &spanner.Partition{}
+	if err := p.Partition.UnmarshalBinary(ebp.Partition); err != nil {
+		return err
+	}
+	p.ReadTimestamp = ebp.ReadTimestamp
+	return nil
+}
@@ -0,0 +1,75 @@
+package spankeys_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+	"github.com/castaneai/spankeys/testutils"
+)
+
+func TestPartitionsRead(t *testing.T) {
+	tableName := "BatchPartitionTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID STRING(36) NOT NULL,
+    Name STRING(255) NOT NULL,
+) PRIMARY KEY (ID)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ms []*spanner.Mutation
+	for i := 0; i < 1000; i++ {
+		id := uuid.Must(uuid.NewRandom()).String()
+		name := uuid.Must(uuid.NewRandom()).String()
+		ms = append(ms, spanner.Insert(tableName, []string{"ID", "Name"}, []interface{}{id, name}))
+	}
+	if _, err := c.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	ps, err := spankeys.PartitionsRead(ctx, c, tableName, []string{"ID", "Name"}, spanner.StrongRead(), spanner.PartitionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, len(ps) > 0)
+
+	// execute serialized partitions by another client as a separate worker does
+	worker, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, p := range ps {
+		data, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wp spankeys.BatchPartition
+		if err := wp.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, p.ReadTimestamp.Equal(wp.ReadTimestamp))
+		if err := wp.Execute(ctx, worker).Do(func(r *spanner.Row) error {
+			total++
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, int64(1000), total)
+	ps[0].Cleanup(ctx, c)
+}
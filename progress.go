@@ -0,0 +1,135 @@
+package spankeys
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// Observer receives the progress of key range operations.
+// Its methods can be called from multiple goroutines at the same time.
+//
+// While partitioning, OnRangePartitioned is called when the scan closes a key range, with the duration of scanning it.
+// While running key ranges, OnRangeStarted and OnRangeCompleted are called before and after processing each key range,
+// so that the same Observer can be passed to both without counting the rows twice.
+type Observer interface {
+	OnRangePartitioned(r *CountableKeyRange, duration time.Duration)
+	OnRangeStarted(r *CountableKeyRange)
+	OnRangeCompleted(r *CountableKeyRange, rowCount int64, duration time.Duration)
+	// OnError is called with a nil key range if the error is not of a key range (e.g. a failed scan).
+	OnError(r *CountableKeyRange, err error)
+}
+
+// ProgressLogger is an Observer which prints the throughput and ETA.
+type ProgressLogger struct {
+	w         io.Writer
+	totalRows int64
+	// Interval is the minimum interval of printing progress. If zero, it prints every completed key range.
+	Interval time.Duration
+
+	mu          sync.Mutex
+	startedAt   time.Time
+	lastPrinted time.Time
+	rows        int64
+	ranges      int64
+	// foundRows and foundRanges are counted while partitioning.
+	foundRows   int64
+	foundRanges int64
+}
+
+// NewProgressLogger returns a ProgressLogger. ETA is printed only if totalRows is positive (see CountRows).
+// The throughput is measured from the first event, so the logger can be created before the operation starts.
+func NewProgressLogger(w io.Writer, totalRows int64) *ProgressLogger {
+	return &ProgressLogger{w: w, totalRows: totalRows}
+}
+
+// OnRangePartitioned prints the key ranges and the rows found by the scan so far.
+// They are not counted as progress, which is counted by processed key ranges.
+func (l *ProgressLogger) OnRangePartitioned(r *CountableKeyRange, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.start()
+	l.foundRows += r.RowCount
+	l.foundRanges++
+	if l.Interval > 0 && now.Sub(l.lastPrinted) < l.Interval {
+		return
+	}
+	l.lastPrinted = now
+	fmt.Fprintf(l.w, "partitioning: %d rows (%d ranges) found\n", l.foundRows, l.foundRanges)
+}
+
+func (l *ProgressLogger) OnRangeStarted(r *CountableKeyRange) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.start()
+}
+
+func (l *ProgressLogger) OnRangeCompleted(r *CountableKeyRange, rowCount int64, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.start()
+	l.rows += rowCount
+	l.ranges++
+	if l.Interval > 0 && now.Sub(l.lastPrinted) < l.Interval && (l.totalRows <= 0 || l.rows < l.totalRows) {
+		return
+	}
+	l.lastPrinted = now
+	fmt.Fprintln(l.w, l.format(now.Sub(l.startedAt)))
+}
+
+// start starts the clock at the first event, and returns the current time.
+func (l *ProgressLogger) start() time.Time {
+	now := time.Now()
+	if l.startedAt.IsZero() {
+		l.startedAt = now
+	}
+	return now
+}
+
+func (l *ProgressLogger) OnError(r *CountableKeyRange, err error) {
+	if r == nil {
+		fmt.Fprintf(l.w, "error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(l.w, "error: key range %s: %v\n", r.KeyRange.String(), err)
+}
+
+func (l *ProgressLogger) format(elapsed time.Duration) string {
+	throughput := 0.0
+	if elapsed > 0 {
+		throughput = float64(l.rows) / elapsed.Seconds()
+	}
+	if l.totalRows <= 0 {
+		return fmt.Sprintf("%d rows (%d ranges), %.1f rows/s", l.rows, l.ranges, throughput)
+	}
+	percent := float64(l.rows) / float64(l.totalRows) * 100
+	eta := "unknown"
+	if throughput > 0 {
+		remaining := l.totalRows - l.rows
+		if remaining < 0 {
+			remaining = 0
+		}
+		eta = time.Duration(float64(remaining) / throughput * float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("%d/%d rows (%.1f%%, %d ranges), %.1f rows/s, ETA %s", l.rows, l.totalRows, percent, l.ranges, throughput, eta)
+}
+
+// CountRows returns the row count of the table.
+func CountRows(ctx context.Context, client *spanner.Client, tableName string) (int64, error) {
+	stmt := spanner.NewStatement(fmt.Sprintf("SELECT COUNT(*) FROM `%s`", tableName))
+	iter := client.Single().Query(ctx, stmt)
+	defer iter.Stop()
+	r, err := iter.Next()
+	if err != nil {
+		return 0, err
+	}
+	var cnt int64
+	if err := r.Column(0, &cnt); err != nil {
+		return 0, err
+	}
+	return cnt, nil
+}
@@ -0,0 +1,81 @@
+package spankeys_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+)
+
+func TestProgressLogger(t *testing.T) {
+	r := &spankeys.CountableKeyRange{
+		KeyRange: spanner.KeyRange{Start: spanner.Key{int64(1)}, End: spanner.Key{int64(100)}, Kind: spanner.ClosedClosed},
+		RowCount: 100,
+	}
+
+	{
+		var buf bytes.Buffer
+		var observer spankeys.Observer = spankeys.NewProgressLogger(&buf, 400)
+		// partitioning is printed, but not counted as progress
+		observer.OnRangePartitioned(r, time.Millisecond)
+		observer.OnRangePartitioned(r, time.Millisecond)
+		observer.OnRangeStarted(r)
+		time.Sleep(10 * time.Millisecond)
+		observer.OnRangeCompleted(r, r.RowCount, 10*time.Millisecond)
+		observer.OnRangeCompleted(r, r.RowCount, 10*time.Millisecond)
+		observer.OnError(r, errors.New("failed"))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Equal(t, 5, len(lines))
+		assert.Equal(t, "partitioning: 100 rows (1 ranges) found", lines[0])
+		assert.Equal(t, "partitioning: 200 rows (2 ranges) found", lines[1])
+		assert.Contains(t, lines[2], "100/400 rows (25.0%, 1 ranges)")
+		assert.Contains(t, lines[2], "ETA")
+		assert.Contains(t, lines[3], "200/400 rows (50.0%, 2 ranges)")
+		assert.Equal(t, "error: key range [(1),(100)]: failed", lines[4])
+	}
+
+	// without the total row count
+	{
+		var buf bytes.Buffer
+		l := spankeys.NewProgressLogger(&buf, 0)
+		l.OnRangeCompleted(r, r.RowCount, time.Millisecond)
+		assert.Contains(t, buf.String(), "100 rows (1 ranges)")
+		assert.NotContains(t, buf.String(), "ETA")
+	}
+
+	// prints at most once per the interval until the end
+	{
+		var buf bytes.Buffer
+		l := spankeys.NewProgressLogger(&buf, 300)
+		l.Interval = time.Hour
+		for i := 0; i < 3; i++ {
+			l.OnRangeCompleted(r, r.RowCount, time.Millisecond)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Equal(t, 2, len(lines))
+		assert.Contains(t, lines[1], "300/300 rows (100.0%, 3 ranges)")
+	}
+
+	// the time before the first event is not counted in the throughput
+	{
+		var buf bytes.Buffer
+		l := spankeys.NewProgressLogger(&buf, 0)
+		time.Sleep(100 * time.Millisecond)
+		l.OnRangeStarted(r)
+		time.Sleep(10 * time.Millisecond)
+		l.OnRangeCompleted(r, r.RowCount, 10*time.Millisecond)
+		var throughput float64
+		if _, err := fmt.Sscanf(buf.String(), "100 rows (1 ranges), %f rows/s", &throughput); err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, throughput > 1000)
+	}
+}
@@ -0,0 +1,102 @@
+package spankeys
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// PurgeOlderThan deletes rows whose timestampColumn is older than the cutoff, like TTL.
+// Rows are deleted by their keys in batches, and each batch keeps the mutations
+// including the cascaded descendant rows within the limit of a commit.
+// Each row is checked again in the deleting transaction, so a row updated after partitioning is not deleted.
+// A failed purge can be resumed by calling it again with the same cutoff, because the deleted rows no longer match.
+func PurgeOlderThan(ctx context.Context, client *spanner.Client, tableName, timestampColumn string, cutoff time.Time, opts *DeleteOptions) (*DeleteReport, error) {
+	if opts == nil {
+		opts = &DeleteOptions{}
+	}
+	pkCols, err := GetPrimaryKeyColumns(ctx, client, tableName)
+	if err != nil {
+		return nil, err
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries < 1 {
+		maxRetries = DefaultMaxRetries
+	}
+
+	it, err := NewCascadeKeyRangeIterator(ctx, client, tableName, pkCols, &KeyRangeOptions{
+		MutationBatchSize: opts.MutationBatchSize,
+		PageSize:          opts.PageSize,
+		Where:             fmt.Sprintf("`%s` < @spankeysCutoff", timestampColumn),
+		WhereParams:       map[string]interface{}{"spankeysCutoff": cutoff},
+		CollectKeys:       true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// deleting by keys costs a mutation per row instead of a mutation per key range
+	it.cascade.rowMutations = 1
+	it.builder.maxMutations = MaxMutationsPerCommit
+
+	run, err := RunKeyRanges(ctx, it, func(ctx context.Context, r *CountableKeyRange) error {
+		return purgeKeys(ctx, client, tableName, pkCols, timestampColumn, cutoff, r, maxRetries)
+	}, &RunOptions{
+		Concurrency: opts.Concurrency,
+		Checkpoint:  opts.Checkpoint,
+		Throttle:    opts.Throttle,
+		Observer:    opts.Observer,
+	})
+	if run == nil {
+		return nil, err
+	}
+	return &DeleteReport{DeletedRanges: run.Completed, DeletedRowCount: run.RowCount}, err
+}
+
+// purgeKeys deletes the rows of the keys which still match the cutoff, and updates RowCount to the deleted row count.
+func purgeKeys(ctx context.Context, client *spanner.Client, tableName string, pkCols []*Column, timestampColumn string, cutoff time.Time, r *CountableKeyRange, maxRetries int) error {
+	columns := []string{timestampColumn}
+	for _, col := range pkCols {
+		columns = append(columns, col.Name)
+	}
+	var deleted int64
+	if err := runTransactionWithRetry(ctx, client, maxRetries, func(ctx context.Context, tx *spanner.ReadWriteTransaction) (int64, error) {
+		var keys []spanner.Key
+		if err := tx.Read(ctx, tableName, keySetFromKeys(r.Keys), columns).Do(func(row *spanner.Row) error {
+			var ts spanner.NullTime
+			if err := row.ColumnByName(timestampColumn, &ts); err != nil {
+				return err
+			}
+			if !ts.Valid || !ts.Time.Before(cutoff) {
+				return nil
+			}
+			key, err := decodeKey(row, pkCols)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		deleted = int64(len(keys))
+		if len(keys) < 1 {
+			return 0, nil
+		}
+		// a deleted key is a mutation
+		return deleted, tx.BufferWrite([]*spanner.Mutation{spanner.Delete(tableName, keySetFromKeys(keys))})
+	}); err != nil {
+		return err
+	}
+	r.RowCount = deleted
+	return nil
+}
+
+func keySetFromKeys(keys []spanner.Key) spanner.KeySet {
+	var kss []spanner.KeySet
+	for _, key := range keys {
+		kss = append(kss, key)
+	}
+	return spanner.KeySets(kss...)
+}
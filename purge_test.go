@@ -0,0 +1,98 @@
+package spankeys_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+	"github.com/castaneai/spankeys/testutils"
+)
+
+func TestPurgeOlderThan(t *testing.T) {
+	parentTableName := "PurgeTestParent"
+	childTableName := "PurgeTestChild"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ParentID STRING(36) NOT NULL,
+    CreatedAt TIMESTAMP NOT NULL,
+) PRIMARY KEY (ParentID)
+`, parentTableName), fmt.Sprintf(`
+CREATE INDEX %s_CreatedAt ON %s(CreatedAt)
+`, parentTableName, parentTableName), fmt.Sprintf(`
+CREATE TABLE %s (
+    ParentID STRING(36) NOT NULL,
+    ChildID STRING(36) NOT NULL,
+) PRIMARY KEY (ParentID, ChildID), INTERLEAVE IN PARENT %s ON DELETE CASCADE
+`, childTableName, parentTableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cutoff := time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		var ms []*spanner.Mutation
+		for j := 0; j < 1000; j++ {
+			parentID := uuid.Must(uuid.NewRandom()).String()
+			createdAt := cutoff.Add(time.Duration(j) * time.Second)
+			// a half of the rows are old
+			if j%2 == 0 {
+				createdAt = cutoff.Add(-time.Duration(j+1) * time.Second)
+			}
+			ms = append(ms, spanner.Insert(parentTableName, []string{"ParentID", "CreatedAt"}, []interface{}{parentID, createdAt}))
+			ms = append(ms, spanner.Insert(childTableName, []string{"ParentID", "ChildID"}, []interface{}{parentID, uuid.Must(uuid.NewRandom()).String()}))
+		}
+		if _, err := c.Apply(ctx, ms); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := spankeys.PurgeOlderThan(ctx, c, parentTableName, "CreatedAt", cutoff, &spankeys.DeleteOptions{
+		Concurrency: 2,
+		PageSize:    1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(2000), report.DeletedRowCount)
+
+	{
+		cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s", parentTableName), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, int64(2000), cnt)
+	}
+	{
+		cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s where CreatedAt < '2019-12-01T00:00:00Z'", parentTableName), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, int64(0), cnt)
+	}
+	{
+		cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s", childTableName), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, int64(2000), cnt)
+	}
+
+	// purging again deletes nothing
+	report, err = spankeys.PurgeOlderThan(ctx, c, parentTableName, "CreatedAt", cutoff, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(0), report.DeletedRowCount)
+}
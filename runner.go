@@ -0,0 +1,238 @@
+package spankeys
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+)
+
+// RangeError is an error occurred while processing a key range.
+type RangeError struct {
+	Range *CountableKeyRange
+	Err   error
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("key range %s: %v", e.Range.KeyRange.String(), e.Err)
+}
+
+// RangeErrors aggregates errors of all failed key ranges.
+type RangeErrors []*RangeError
+
+func (e RangeErrors) Error() string {
+	var msgs []string
+	for _, re := range e {
+		msgs = append(msgs, re.Error())
+	}
+	return fmt.Sprintf("%d key range(s) failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// RangeFunc processes a key range. It can be called again for the same key range on retries or resuming, so it should be idempotent.
+type RangeFunc func(ctx context.Context, r *CountableKeyRange) error
+
+// CheckpointError is an error occurred while saving the checkpoint after a key range was processed.
+type CheckpointError struct {
+	Range *CountableKeyRange
+	Err   error
+}
+
+func (e *CheckpointError) Error() string {
+	return fmt.Sprintf("checkpoint after key range %s: %v", e.Range.KeyRange.String(), e.Err)
+}
+
+// CheckpointErrors aggregates errors of saving the checkpoint.
+type CheckpointErrors []*CheckpointError
+
+func (e CheckpointErrors) Error() string {
+	var msgs []string
+	for _, ce := range e {
+		msgs = append(msgs, ce.Error())
+	}
+	return fmt.Sprintf("%d checkpoint(s) failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+type RunOptions struct {
+	// Concurrency is the number of key ranges processed at the same time (default: 1).
+	Concurrency int
+
+	// Checkpoint persists the progress after each key range is completed.
+	// If it has a checkpoint already, the scan resumes after the checkpoint and the completed key ranges are skipped.
+	Checkpoint CheckpointStore
+
+	// Throttle limits the rate of commits. The transactions of this package wait for it before each commit
+	// with the mutations of the commit (a mutation per column of each written row, or one per deleted key range).
+	// If fn commits by other means, the key range is counted as a commit of MutationCount mutations,
+	// or RowCount mutations if MutationCount is not set, after fn returns.
+	Throttle *Throttle
+
+	// Observer receives the progress of processing key ranges.
+	Observer Observer
+}
+
+type RunReport struct {
+	// Completed are the key ranges processed by the function, in the order of completion.
+	Completed []*CountableKeyRange
+
+	// Skipped are the key ranges completed in the previous run, found in the checkpoint.
+	Skipped []*CountableKeyRange
+
+	// RowCount is the total row count of the completed key ranges.
+	RowCount int64
+
+	// CheckpointErrors are the key ranges processed by the function but failed to save the checkpoint.
+	// They are not in Completed because a resumed job can process them again.
+	CheckpointErrors CheckpointErrors
+}
+
+// RunKeyRanges calls fn for each key range yielded by the iterator with at most Concurrency goroutines,
+// so that the first key ranges are processed while the iterator is still scanning.
+// A failed key range does not stop the others; all errors are returned as RangeErrors with the report.
+// If no key range failed but saving the checkpoint failed, CheckpointErrors is returned instead.
+func RunKeyRanges(ctx context.Context, it *KeyRangeIterator, fn RangeFunc, opts *RunOptions) (*RunReport, error) {
+	if opts == nil {
+		opts = &RunOptions{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	defer it.Stop()
+
+	var cp *Checkpoint
+	if opts.Checkpoint != nil {
+		loaded, err := opts.Checkpoint.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cp = loaded
+		if cp != nil && cp.LastKey != nil {
+			it.resumeAfter(cp.LastKey)
+		}
+	}
+	progress := newCheckpointProgress(opts.Checkpoint, it.pkColumns, cp)
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		report = &RunReport{}
+		errs   RangeErrors
+	)
+	type task struct {
+		seq int
+		r   *CountableKeyRange
+	}
+	queue := make(chan *task)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				err := runRange(ctx, t.r, fn, opts.Throttle, opts.Observer)
+				var cpErr error
+				if err == nil {
+					cpErr = progress.save(ctx, progress.complete(t.seq, t.r))
+				}
+				mu.Lock()
+				switch {
+				case err != nil:
+					errs = append(errs, &RangeError{Range: t.r, Err: err})
+				case cpErr != nil:
+					report.CheckpointErrors = append(report.CheckpointErrors, &CheckpointError{Range: t.r, Err: cpErr})
+				default:
+					report.Completed = append(report.Completed, t.r)
+					report.RowCount += t.r.RowCount
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	var scanErr error
+	for seq := 0; ; seq++ {
+		r, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			scanErr = err
+			break
+		}
+		if progress.isCompleted(r) {
+			cpErr := progress.save(ctx, progress.complete(seq, r))
+			mu.Lock()
+			report.Skipped = append(report.Skipped, r)
+			if cpErr != nil {
+				report.CheckpointErrors = append(report.CheckpointErrors, &CheckpointError{Range: r, Err: cpErr})
+			}
+			mu.Unlock()
+			continue
+		}
+		select {
+		case queue <- &task{seq: seq, r: r}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if scanErr != nil {
+		return report, scanErr
+	}
+	if len(errs) > 0 {
+		return report, errs
+	}
+	if len(report.CheckpointErrors) > 0 {
+		return report, report.CheckpointErrors
+	}
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// runRange throttles the commits made by the transactions of this package in fn,
+// or the whole key range if fn commits by other means.
+func runRange(ctx context.Context, r *CountableKeyRange, fn RangeFunc, throttle *Throttle, observer Observer) error {
+	var commits *commitThrottle
+	if throttle != nil {
+		ctx, commits = withCommitThrottle(ctx, throttle)
+	}
+	if observer != nil {
+		observer.OnRangeStarted(r)
+	}
+	start := time.Now()
+	err := fn(ctx, r)
+	duration := time.Since(start)
+	if throttle != nil && !commits.committed {
+		switch {
+		case err == nil:
+			throttle.Observe(duration, 1, 0)
+		case spanner.ErrCode(err) == codes.Aborted:
+			throttle.Observe(0, 1, 1)
+		}
+	}
+	if observer != nil {
+		if err != nil {
+			observer.OnError(r, err)
+		} else {
+			observer.OnRangeCompleted(r, r.RowCount, duration)
+		}
+	}
+	if throttle != nil && !commits.committed {
+		mutations := r.MutationCount
+		if mutations < 1 {
+			mutations = r.RowCount
+		}
+		// the wait delays the next key range; its error is the one of ctx, which RunKeyRanges reports
+		_ = throttle.Wait(ctx, mutations)
+	}
+	return err
+}
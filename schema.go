@@ -3,6 +3,7 @@ package spankeys
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"cloud.google.com/go/spanner"
 )
@@ -10,14 +11,61 @@ import (
 type Table struct {
 	Name       string
 	Interleave *Interleave
+
+	// Columns and PrimaryKey are set only by GetSchema.
+	Columns    []*Column
+	PrimaryKey []*Column
 }
 
 type Column struct {
-	Name            string
+	Name string
 
 	// ORDINAL_POSITION is nullable
 	// https://cloud.google.com/spanner/docs/information-schema#information_schemaindex_columns
 	OrdinalPosition spanner.NullInt64
+
+	// Ordering is the COLUMN_ORDERING of a key column.
+	// It is ColumnOrderingUnknown for a non-key column.
+	Ordering ColumnOrdering
+
+	// SpannerType is the SPANNER_TYPE of the column (e.g. STRING(MAX), ARRAY<INT64>).
+	// It is set only by GetColumns.
+	SpannerType string
+
+	// IsNullable is the IS_NULLABLE of the column. It is set only by GetColumns and GetIndexes.
+	IsNullable bool
+
+	// The following fields are set only by GetColumns.
+
+	// Type is the parsed SpannerType. It is nil if the type is not supported by ParseSpannerType.
+	Type *SpannerType
+
+	// AllowCommitTimestamp is the allow_commit_timestamp option of a TIMESTAMP column.
+	AllowCommitTimestamp bool
+
+	// GenerationExpression is the expression of a generated column, and IsStored is true if it is STORED.
+	GenerationExpression string
+	IsStored             bool
+
+	// Default is the expression of the DEFAULT value.
+	Default string
+}
+
+// IsGenerated reports whether the column is a generated column.
+func (c *Column) IsGenerated() bool {
+	return c.GenerationExpression != ""
+}
+
+type ColumnOrdering string
+
+const (
+	ColumnOrderingUnknown ColumnOrdering = ""
+	ColumnOrderingAsc     ColumnOrdering = "ASC"
+	ColumnOrderingDesc    ColumnOrdering = "DESC"
+)
+
+func (c *Column) IsDesc() bool {
+	return c.Ordering == ColumnOrderingDesc
 }
 
 type IndexColumn struct {
@@ -58,8 +106,10 @@ type Index struct {
 	IsPrimaryKey   bool
 	IsUnique       bool
 	IsNullFiltered bool
-	State          IndexState
-	Columns        []*IndexColumn
+	// IsManaged is true for the backing index of a foreign key, which is created by Cloud Spanner.
+	IsManaged bool
+	State     IndexState
+	Columns   []*IndexColumn
 }
 
 func GetTables(ctx context.Context, client *spanner.Client) ([]*Table, error) {
@@ -101,6 +151,51 @@ where TABLE_SCHEMA = ''
 	return ts, nil
 }
 
+// TableNode is a table with its interleaved child tables.
+type TableNode struct {
+	*Table
+	Children []*TableNode
+}
+
+// Tables returns the table and all of its descendants in parent-before-child order.
+func (n *TableNode) Tables() []*Table {
+	var ts []*Table
+	queue := []*TableNode{n}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		ts = append(ts, node.Table)
+		queue = append(queue, node.Children...)
+	}
+	return ts
+}
+
+// BuildInterleaveTree returns the root tables of the interleave hierarchy sorted by name.
+func BuildInterleaveTree(tables []*Table) ([]*TableNode, error) {
+	nodes := make(map[string]*TableNode, len(tables))
+	var names []string
+	for _, t := range tables {
+		nodes[t.Name] = &TableNode{Table: t}
+		names = append(names, t.Name)
+	}
+	sort.Strings(names)
+
+	var roots []*TableNode
+	for _, name := range names {
+		node := nodes[name]
+		if node.Interleave == nil {
+			roots = append(roots, node)
+			continue
+		}
+		parent, ok := nodes[node.Interleave.Table]
+		if !ok {
+			return nil, fmt.Errorf("parent table %s of %s not found", node.Interleave.Table, name)
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	return roots, nil
+}
+
 func GetInterleaveChildren(ctx context.Context, client *spanner.Client, parentTable string) ([]*Interleave, error) {
 	stmt := spanner.NewStatement(fmt.Sprintf(`
 select * from INFORMATION_SCHEMA.TABLES
@@ -169,14 +264,16 @@ indexes.PARENT_TABLE_NAME,
 indexes.IS_UNIQUE,
 indexes.IS_NULL_FILTERED,
 indexes.INDEX_STATE,
+indexes.SPANNER_IS_MANAGED,
 index_columns.TABLE_NAME,
 index_columns.COLUMN_NAME,
 index_columns.ORDINAL_POSITION,
+index_columns.COLUMN_ORDERING,
 index_columns.IS_NULLABLE
 from INFORMATION_SCHEMA.INDEX_COLUMNS
 left join INFORMATION_SCHEMA.INDEXES
-using (INDEX_NAME)
-where INDEX_COLUMNS.TABLE_SCHEMA = '' and INDEXES.TABLE_SCHEMA = ''
+using (TABLE_SCHEMA, TABLE_NAME, INDEX_NAME)
+where INDEX_COLUMNS.TABLE_SCHEMA = ''
 order by ORDINAL_POSITION`)
 
 	indexes := make(map[string]*Index)
@@ -218,6 +315,10 @@ order by ORDINAL_POSITION`)
 			if state.Valid {
 				stt = IndexState(state.StringVal)
 			}
+			var isManaged spanner.NullBool
+			if err := r.ColumnByName("SPANNER_IS_MANAGED", &isManaged); err != nil {
+				return err
+			}
 			indexes[key] = &Index{
 				Name:           name,
 				Type:           IndexType(itype),
@@ -226,6 +327,7 @@ order by ORDINAL_POSITION`)
 				IsPrimaryKey:   name == "PRIMARY_KEY",
 				IsUnique:       isUnique,
 				IsNullFiltered: isNullFiltered,
+				IsManaged:      isManaged.Bool,
 				State:          stt,
 			}
 		}
@@ -238,10 +340,18 @@ order by ORDINAL_POSITION`)
 		if err := r.ColumnByName("ORDINAL_POSITION", &op); err != nil {
 			return err
 		}
+		var ordering spanner.NullString
+		if err := r.ColumnByName("COLUMN_ORDERING", &ordering); err != nil {
+			return err
+		}
+		var isNullable string
+		if err := r.ColumnByName("IS_NULLABLE", &isNullable); err != nil {
+			return err
+		}
 		colKey := fmt.Sprintf("%s_%s", key, colName)
 		if _, exists := colKeys[colKey]; !exists {
 			indexes[key].Columns = append(indexes[key].Columns, &IndexColumn{
-				Column{Name: colName, OrdinalPosition: op},
+				Column{Name: colName, OrdinalPosition: op, Ordering: ColumnOrdering(ordering.StringVal), IsNullable: isNullable == "YES"},
 			})
 			colKeys[colKey] = struct{}{}
 		}
@@ -258,7 +368,17 @@ order by ORDINAL_POSITION`)
 }
 
 func GetColumns(ctx context.Context, client *spanner.Client, table string) ([]*Column, error) {
-	stmt := spanner.NewStatement("select column_name, ordinal_position from INFORMATION_SCHEMA.COLUMNS where table_name = @tableName order by ordinal_position")
+	stmt := spanner.NewStatement(`
+select c.column_name, c.ordinal_position, c.spanner_type, c.is_nullable,
+c.generation_expression, c.is_stored, c.column_default,
+exists(
+  select 1 from INFORMATION_SCHEMA.COLUMN_OPTIONS as o
+  where o.table_schema = c.table_schema and o.table_name = c.table_name and o.column_name = c.column_name
+  and o.option_name = 'allow_commit_timestamp' and o.option_value = 'TRUE'
+) as allow_commit_timestamp
+from INFORMATION_SCHEMA.COLUMNS as c
+where c.table_schema = '' and c.table_name = @tableName
+order by c.ordinal_position`)
 	stmt.Params["tableName"] = table
 	var cols []*Column
 	if err := client.Single().Query(ctx, stmt).Do(func(r *spanner.Row) error {
@@ -270,7 +390,41 @@ func GetColumns(ctx context.Context, client *spanner.Client, table string) ([]*C
 		if err := r.Column(1, &op); err != nil {
 			return err
 		}
-		cols = append(cols, &Column{Name: name, OrdinalPosition: op})
+		var spannerType string
+		if err := r.Column(2, &spannerType); err != nil {
+			return err
+		}
+		var isNullable string
+		if err := r.Column(3, &isNullable); err != nil {
+			return err
+		}
+		var generation, isStored, def spanner.NullString
+		if err := r.Column(4, &generation); err != nil {
+			return err
+		}
+		if err := r.Column(5, &isStored); err != nil {
+			return err
+		}
+		if err := r.Column(6, &def); err != nil {
+			return err
+		}
+		var allowCommitTimestamp bool
+		if err := r.Column(7, &allowCommitTimestamp); err != nil {
+			return err
+		}
+		// an unsupported type is left as nil not to fail the other columns
+		typ, _ := ParseSpannerType(spannerType)
+		cols = append(cols, &Column{
+			Name:                 name,
+			OrdinalPosition:      op,
+			SpannerType:          spannerType,
+			IsNullable:           isNullable == "YES",
+			Type:                 typ,
+			AllowCommitTimestamp: allowCommitTimestamp,
+			GenerationExpression: generation.StringVal,
+			IsStored:             isStored.StringVal == "YES",
+			Default:              def.StringVal,
+		})
 		return nil
 	}); err != nil {
 		return nil, err
@@ -279,7 +433,7 @@ func GetColumns(ctx context.Context, client *spanner.Client, table string) ([]*C
 }
 
 func GetPrimaryKeyColumns(ctx context.Context, client *spanner.Client, table string) ([]*Column, error) {
-	stmt := spanner.NewStatement("select column_name, ordinal_position from INFORMATION_SCHEMA.INDEX_COLUMNS where table_name = @tableName and index_type = 'PRIMARY_KEY' order by ordinal_position")
+	stmt := spanner.NewStatement("select column_name, ordinal_position, column_ordering from INFORMATION_SCHEMA.INDEX_COLUMNS where table_schema = '' and table_name = @tableName and index_type = 'PRIMARY_KEY' order by ordinal_position")
 	stmt.Params["tableName"] = table
 	var pks []*Column
 	if err := client.Single().Query(ctx, stmt).Do(func(r *spanner.Row) error {
@@ -291,10 +445,115 @@ func GetPrimaryKeyColumns(ctx context.Context, client *spanner.Client, table str
 		if err := r.Column(1, &op); err != nil {
 			return err
 		}
-		pks = append(pks, &Column{Name: name, OrdinalPosition: op})
+		var ordering spanner.NullString
+		if err := r.Column(2, &ordering); err != nil {
+			return err
+		}
+		pks = append(pks, &Column{Name: name, OrdinalPosition: op, Ordering: ColumnOrdering(ordering.StringVal)})
 		return nil
 	}); err != nil {
 		return nil, err
 	}
 	return pks, nil
 }
+
+// ForeignKey is a FOREIGN KEY constraint. Columns of Table reference ReferencedColumns of ReferencedTable.
+type ForeignKey struct {
+	Name              string
+	Table             string
+	Columns           []string
+	ReferencedTable   string
+	ReferencedColumns []string
+
+	// IsUnnamed reports whether the name is not written in the DDL parsed by ParseDDL.
+	// Name is generated by ParseDDL then, which differs from the name generated by Cloud Spanner.
+	IsUnnamed bool
+}
+
+func GetForeignKeys(ctx context.Context, client *spanner.Client) ([]*ForeignKey, error) {
+	stmt := spanner.NewStatement(`
+select rc.CONSTRAINT_NAME, kcu.TABLE_NAME, kcu.COLUMN_NAME, ukcu.TABLE_NAME, ukcu.COLUMN_NAME
+from INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS as rc
+join INFORMATION_SCHEMA.KEY_COLUMN_USAGE as kcu
+  on kcu.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA and kcu.CONSTRAINT_NAME = rc.CONSTRAINT_NAME
+join INFORMATION_SCHEMA.KEY_COLUMN_USAGE as ukcu
+  on ukcu.CONSTRAINT_SCHEMA = rc.UNIQUE_CONSTRAINT_SCHEMA and ukcu.CONSTRAINT_NAME = rc.UNIQUE_CONSTRAINT_NAME
+  and ukcu.ORDINAL_POSITION = kcu.POSITION_IN_UNIQUE_CONSTRAINT
+where rc.CONSTRAINT_SCHEMA = ''
+order by rc.CONSTRAINT_NAME, kcu.ORDINAL_POSITION
+`)
+	var fks []*ForeignKey
+	if err := client.Single().Query(ctx, stmt).Do(func(r *spanner.Row) error {
+		var name, table, column, refTable, refColumn string
+		if err := r.Columns(&name, &table, &column, &refTable, &refColumn); err != nil {
+			return err
+		}
+		if len(fks) < 1 || fks[len(fks)-1].Name != name {
+			fks = append(fks, &ForeignKey{Name: name, Table: table, ReferencedTable: refTable})
+		}
+		fk := fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, column)
+		fk.ReferencedColumns = append(fk.ReferencedColumns, refColumn)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return fks, nil
+}
+
+// SortTablesByDependency sorts the tables so that interleave parents and tables referenced by foreign keys come first.
+// Tables without dependencies between them are sorted by name.
+// It returns an error if the foreign keys are cyclic, which Cloud Spanner allows;
+// such tables can be written by adding the foreign keys after writing the data, as Restore does.
+func SortTablesByDependency(tables []*Table, fks []*ForeignKey) ([]*Table, error) {
+	byName := make(map[string]*Table, len(tables))
+	deps := make(map[string][]string, len(tables))
+	var names []string
+	for _, t := range tables {
+		byName[t.Name] = t
+		names = append(names, t.Name)
+		if t.Interleave != nil {
+			deps[t.Name] = append(deps[t.Name], t.Interleave.Table)
+		}
+	}
+	for _, fk := range fks {
+		if fk.Table != fk.ReferencedTable {
+			deps[fk.Table] = append(deps[fk.Table], fk.ReferencedTable)
+		}
+	}
+	sort.Strings(names)
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(tables))
+	var sorted []*Table
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("tables have a cyclic dependency at %s", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if _, ok := byName[dep]; !ok {
+				return fmt.Errorf("table %s depends on unknown table %s", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		sorted = append(sorted, byName[name])
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
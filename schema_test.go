@@ -88,6 +88,54 @@ CREATE TABLE TestGetColumns (
 	assert.Equal(t, spanner.NullInt64{Int64: 2, Valid: true}, cols[1].OrdinalPosition)
 	assert.Equal(t, "Age", cols[2].Name)
 	assert.Equal(t, spanner.NullInt64{Int64: 3, Valid: true}, cols[2].OrdinalPosition)
+	assert.Equal(t, "STRING(36)", cols[0].SpannerType)
+	assert.Equal(t, "STRING(255)", cols[1].SpannerType)
+	assert.Equal(t, "INT64", cols[2].SpannerType)
+}
+
+func TestGetColumnsMetadata(t *testing.T) {
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{`
+CREATE TABLE TestGetColumnsMetadata (
+    ID INT64 NOT NULL,
+    Name STRING(MAX),
+    Tags ARRAY<STRING(16)>,
+    Status STRING(16) NOT NULL DEFAULT ("active"),
+    UpperName STRING(MAX) AS (UPPER(Name)) STORED,
+    UpdatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+) PRIMARY KEY (ID)
+`}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cols, err := spankeys.GetColumns(ctx, c, "TestGetColumnsMetadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 6, len(cols))
+
+	assert.False(t, cols[0].IsNullable)
+	assert.Equal(t, &spankeys.SpannerType{Code: spankeys.TypeInt64}, cols[0].Type)
+
+	assert.True(t, cols[1].IsNullable)
+	assert.Equal(t, &spankeys.SpannerType{Code: spankeys.TypeString, IsMax: true}, cols[1].Type)
+
+	assert.Equal(t, &spankeys.SpannerType{Code: spankeys.TypeArray, ElementType: &spankeys.SpannerType{Code: spankeys.TypeString, Length: 16}}, cols[2].Type)
+
+	assert.Equal(t, `"active"`, cols[3].Default)
+	assert.False(t, cols[3].IsGenerated())
+
+	assert.True(t, cols[4].IsGenerated())
+	assert.Equal(t, "UPPER(Name)", cols[4].GenerationExpression)
+	assert.True(t, cols[4].IsStored)
+
+	assert.True(t, cols[5].AllowCommitTimestamp)
+	assert.False(t, cols[4].AllowCommitTimestamp)
 }
 
 func TestGetPrimaryKeyColumns(t *testing.T) {
@@ -304,3 +352,32 @@ INTERLEAVE IN PARENT Parent ON DELETE CASCADE
 		assert.Equal(t, 0, len(is))
 	}
 }
+
+func TestGetPrimaryKeyColumnsOrdering(t *testing.T) {
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{`
+CREATE TABLE DescPK (
+    ID STRING(36) NOT NULL,
+    CreatedAt TIMESTAMP NOT NULL,
+    Name STRING(255) NOT NULL,
+) PRIMARY KEY (ID, CreatedAt DESC)
+`}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pks, err := spankeys.GetPrimaryKeyColumns(ctx, c, "DescPK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, len(pks))
+	assert.Equal(t, "ID", pks[0].Name)
+	assert.Equal(t, spankeys.ColumnOrderingAsc, pks[0].Ordering)
+	assert.Equal(t, "CreatedAt", pks[1].Name)
+	assert.Equal(t, spankeys.ColumnOrderingDesc, pks[1].Ordering)
+	assert.True(t, pks[1].IsDesc())
+}
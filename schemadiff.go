@@ -0,0 +1,262 @@
+package spankeys
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SchemaDiff is the migration from a schema to another.
+type SchemaDiff struct {
+	// Statements are the DDL statements in the order of applying.
+	Statements []string
+
+	// Unsupported are the changes which Cloud Spanner cannot apply in place.
+	// They are not included in Statements, and need to be migrated by hand (e.g. recreating the table or backfilling the column).
+	Unsupported []*UnsupportedChange
+}
+
+// UnsupportedChange is a change which cannot be applied by ALTER statements.
+type UnsupportedChange struct {
+	Table  string
+	Column string
+	Reason string
+}
+
+func (c *UnsupportedChange) String() string {
+	if c.Column == "" {
+		return fmt.Sprintf("table %s: %s", c.Table, c.Reason)
+	}
+	return fmt.Sprintf("table %s column %s: %s", c.Table, c.Column, c.Reason)
+}
+
+// HasChanges reports whether the schemas are different.
+func (d *SchemaDiff) HasChanges() bool {
+	return len(d.Statements) > 0 || len(d.Unsupported) > 0
+}
+
+// DiffSchemas compares the schemas, and returns the statements migrating from to to.
+// Foreign keys and indexes are dropped first and created last, so that the tables and the columns they use can be changed.
+// Tables are dropped children first, and created parents first.
+func DiffSchemas(from, to *Schema) *SchemaDiff {
+	d := &SchemaDiff{}
+
+	droppedFKs, addedFKs := diffForeignKeys(from.ForeignKeys, to.ForeignKeys)
+	for _, fk := range droppedFKs {
+		if fk.IsUnnamed {
+			d.unsupported(fk.Table, "", fmt.Sprintf("unnamed foreign key referencing %s cannot be dropped without the name in the database", fk.ReferencedTable))
+			continue
+		}
+		d.add("ALTER TABLE %s DROP CONSTRAINT %s", quoteIdentifier(fk.Table), quoteIdentifier(fk.Name))
+	}
+	fromIdxes, toIdxes := indexesByName(from.Indexes), indexesByName(to.Indexes)
+	for _, idx := range from.Indexes {
+		if n, ok := toIdxes[idx.Name]; !ok || CreateIndexDDL(n) != CreateIndexDDL(idx) {
+			d.add("DROP INDEX %s", quoteIdentifier(idx.Name))
+		}
+	}
+	for i := len(from.Tables) - 1; i >= 0; i-- {
+		if t := from.Tables[i]; to.Table(t.Name) == nil {
+			d.add("DROP TABLE %s", quoteIdentifier(t.Name))
+		}
+	}
+	for _, t := range to.Tables {
+		if from.Table(t.Name) == nil {
+			d.Statements = append(d.Statements, CreateTableDDL(t))
+		}
+	}
+	for _, t := range to.Tables {
+		if ft := from.Table(t.Name); ft != nil {
+			d.diffTable(ft, t)
+		}
+	}
+	for _, idx := range to.Indexes {
+		if o, ok := fromIdxes[idx.Name]; !ok || CreateIndexDDL(o) != CreateIndexDDL(idx) {
+			d.Statements = append(d.Statements, CreateIndexDDL(idx))
+		}
+	}
+	for _, fk := range addedFKs {
+		d.Statements = append(d.Statements, AddForeignKeyDDL(fk))
+	}
+	return d
+}
+
+// diffForeignKeys returns the foreign keys only in from and the ones only in to.
+// Unnamed foreign keys are matched by the definition, because ParseDDL and Cloud Spanner generate different names.
+func diffForeignKeys(from, to []*ForeignKey) (dropped, added []*ForeignKey) {
+	matched := make(map[*ForeignKey]bool)
+	toByName := foreignKeysByName(to)
+	for _, fk := range from {
+		if n, ok := toByName[fk.Name]; ok && !fk.IsUnnamed && !n.IsUnnamed && sameForeignKey(fk, n) {
+			matched[fk], matched[n] = true, true
+		}
+	}
+	for _, fk := range from {
+		for _, n := range to {
+			if !matched[fk] && !matched[n] && (fk.IsUnnamed || n.IsUnnamed) && sameForeignKey(fk, n) {
+				matched[fk], matched[n] = true, true
+			}
+		}
+	}
+	for _, fk := range from {
+		if !matched[fk] {
+			dropped = append(dropped, fk)
+		}
+	}
+	for _, fk := range to {
+		if !matched[fk] {
+			added = append(added, fk)
+		}
+	}
+	return dropped, added
+}
+
+// sameForeignKey compares the definitions of the foreign keys ignoring the names.
+func sameForeignKey(a, b *ForeignKey) bool {
+	return a.Table == b.Table && a.ReferencedTable == b.ReferencedTable &&
+		quoteIdentifiers(a.Columns) == quoteIdentifiers(b.Columns) &&
+		quoteIdentifiers(a.ReferencedColumns) == quoteIdentifiers(b.ReferencedColumns)
+}
+
+func (d *SchemaDiff) diffTable(from, to *Table) {
+	table := quoteIdentifier(to.Name)
+	// the table needs to be recreated, so no partial migration is generated for it
+	if !samePrimaryKey(from.PrimaryKey, to.PrimaryKey) {
+		d.unsupported(to.Name, "", "primary key cannot be changed")
+		return
+	}
+	switch {
+	case from.Interleave == nil && to.Interleave == nil:
+	case from.Interleave == nil || to.Interleave == nil || from.Interleave.Table != to.Interleave.Table:
+		d.unsupported(to.Name, "", "interleave parent cannot be changed")
+		return
+	case from.Interleave.OnDelete != to.Interleave.OnDelete:
+		d.add("ALTER TABLE %s SET ON DELETE %s", table, to.Interleave.OnDelete)
+	}
+
+	fromCols := columnsByName(from.Columns)
+	toCols := columnsByName(to.Columns)
+	for _, col := range from.Columns {
+		if _, ok := toCols[col.Name]; !ok {
+			d.add("ALTER TABLE %s DROP COLUMN %s", table, quoteIdentifier(col.Name))
+		}
+	}
+	for _, col := range to.Columns {
+		fc, ok := fromCols[col.Name]
+		if !ok {
+			// the existing rows have no value for the column
+			if !col.IsNullable && col.Default == "" && !col.IsGenerated() {
+				d.unsupported(to.Name, col.Name, "NOT NULL column without DEFAULT cannot be added to an existing table; add it as nullable, backfill it and then set NOT NULL")
+				continue
+			}
+			d.add("ALTER TABLE %s ADD COLUMN %s", table, columnDefinition(col))
+			continue
+		}
+		d.diffColumn(to.Name, fc, col, containsColumn(to.PrimaryKey, col.Name))
+	}
+}
+
+func (d *SchemaDiff) diffColumn(tableName string, from, to *Column, isKey bool) {
+	table, name := quoteIdentifier(tableName), quoteIdentifier(to.Name)
+	if from.GenerationExpression != to.GenerationExpression || from.IsStored != to.IsStored {
+		d.unsupported(tableName, to.Name, "generated column cannot be changed")
+		return
+	}
+	fromType, toType := parsedColumnType(from), parsedColumnType(to)
+	typeChanged := columnTypeString(from) != columnTypeString(to)
+	if typeChanged && (isKey || !convertibleTypes(fromType, toType)) {
+		d.unsupported(tableName, to.Name, fmt.Sprintf("type cannot be changed from %s to %s", columnTypeString(from), columnTypeString(to)))
+		return
+	}
+	if isKey && from.IsNullable != to.IsNullable {
+		d.unsupported(tableName, to.Name, "nullability of a primary key column cannot be changed")
+		return
+	}
+	if typeChanged || from.IsNullable != to.IsNullable {
+		def := fmt.Sprintf("%s %s", name, columnTypeString(to))
+		if !to.IsNullable {
+			def += " NOT NULL"
+		}
+		d.add("ALTER TABLE %s ALTER COLUMN %s", table, def)
+	}
+	if from.Default != to.Default {
+		if to.Default == "" {
+			d.add("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT", table, name)
+		} else {
+			d.add("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT (%s)", table, name, to.Default)
+		}
+	}
+	if from.AllowCommitTimestamp != to.AllowCommitTimestamp {
+		value := "null"
+		if to.AllowCommitTimestamp {
+			value = "true"
+		}
+		d.add("ALTER TABLE %s ALTER COLUMN %s SET OPTIONS (allow_commit_timestamp=%s)", table, name, value)
+	}
+}
+
+func (d *SchemaDiff) add(format string, args ...interface{}) {
+	d.Statements = append(d.Statements, fmt.Sprintf(format, args...))
+}
+
+func (d *SchemaDiff) unsupported(table, column, reason string) {
+	d.Unsupported = append(d.Unsupported, &UnsupportedChange{Table: table, Column: column, Reason: reason})
+}
+
+// convertibleTypes reports whether ALTER COLUMN can change the type,
+// which is allowed between STRING and BYTES of any length (also as the element of ARRAY).
+func convertibleTypes(from, to *SpannerType) bool {
+	if from == nil || to == nil {
+		return false
+	}
+	if from.Code == TypeArray && to.Code == TypeArray {
+		return convertibleTypes(from.ElementType, to.ElementType)
+	}
+	isStringOrBytes := func(t *SpannerType) bool {
+		return t.Code == TypeString || t.Code == TypeBytes
+	}
+	return isStringOrBytes(from) && isStringOrBytes(to)
+}
+
+func samePrimaryKey(a, b []*Column) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].IsDesc() != b[i].IsDesc() {
+			return false
+		}
+	}
+	return true
+}
+
+// columnTypeString returns the normalized type of the column.
+func columnTypeString(col *Column) string {
+	if t := parsedColumnType(col); t != nil {
+		return t.String()
+	}
+	return strings.ToUpper(col.SpannerType)
+}
+
+func columnsByName(cols []*Column) map[string]*Column {
+	m := make(map[string]*Column, len(cols))
+	for _, col := range cols {
+		m[col.Name] = col
+	}
+	return m
+}
+
+func indexesByName(idxes []*Index) map[string]*Index {
+	m := make(map[string]*Index, len(idxes))
+	for _, idx := range idxes {
+		m[idx.Name] = idx
+	}
+	return m
+}
+
+func foreignKeysByName(fks []*ForeignKey) map[string]*ForeignKey {
+	m := make(map[string]*ForeignKey, len(fks))
+	for _, fk := range fks {
+		m[fk.Name] = fk
+	}
+	return m
+}
@@ -0,0 +1,115 @@
+package spankeys_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	key := func(name string) *spankeys.Column {
+		return &spankeys.Column{Name: name, Ordering: spankeys.ColumnOrderingAsc}
+	}
+	indexColumn := func(name string, pos int64) *spankeys.IndexColumn {
+		return &spankeys.IndexColumn{Column: spankeys.Column{Name: name, OrdinalPosition: spanner.NullInt64{Int64: pos, Valid: true}}}
+	}
+	from := &spankeys.Schema{
+		Tables: []*spankeys.Table{
+			{Name: "Singer", Columns: []*spankeys.Column{
+				{Name: "SingerID", SpannerType: "INT64"},
+				{Name: "Name", SpannerType: "STRING(255)", IsNullable: true},
+				{Name: "Old", SpannerType: "INT64", IsNullable: true},
+				{Name: "UpdatedAt", SpannerType: "TIMESTAMP"},
+			}, PrimaryKey: []*spankeys.Column{key("SingerID")}},
+			{Name: "Album", Interleave: &spankeys.Interleave{Table: "Singer"}, Columns: []*spankeys.Column{
+				{Name: "SingerID", SpannerType: "INT64"},
+				{Name: "AlbumID", SpannerType: "INT64"},
+				{Name: "Score", SpannerType: "INT64", IsNullable: true},
+			}, PrimaryKey: []*spankeys.Column{key("SingerID"), key("AlbumID")}},
+			{Name: "Song", Interleave: &spankeys.Interleave{Table: "Singer"}, Columns: []*spankeys.Column{
+				{Name: "SingerID", SpannerType: "INT64"},
+				{Name: "SongID", SpannerType: "INT64"},
+			}, PrimaryKey: []*spankeys.Column{key("SingerID"), key("SongID")}},
+			{Name: "Legacy", Columns: []*spankeys.Column{
+				{Name: "ID", SpannerType: "INT64"},
+			}, PrimaryKey: []*spankeys.Column{key("ID")}},
+		},
+		Indexes: []*spankeys.Index{
+			{Name: "SingerByName", Table: "Singer", Columns: []*spankeys.IndexColumn{indexColumn("Name", 1)}},
+			{Name: "SingerByOld", Table: "Singer", Columns: []*spankeys.IndexColumn{indexColumn("Old", 1)}},
+		},
+		ForeignKeys: []*spankeys.ForeignKey{
+			// the name generated by Cloud Spanner
+			{Name: "FK_Song_Singer_7B1FEAD5C4A3C4F2", Table: "Song", Columns: []string{"SingerID"}, ReferencedTable: "Singer", ReferencedColumns: []string{"SingerID"}},
+			{Name: "FK_Singer_Legacy_1", IsUnnamed: true, Table: "Singer", Columns: []string{"Old"}, ReferencedTable: "Legacy", ReferencedColumns: []string{"ID"}},
+		},
+	}
+	to := &spankeys.Schema{
+		Tables: []*spankeys.Table{
+			{Name: "Singer", Columns: []*spankeys.Column{
+				{Name: "SingerID", SpannerType: "INT64"},
+				{Name: "Name", SpannerType: "STRING(MAX)"},
+				{Name: "UpdatedAt", SpannerType: "TIMESTAMP", AllowCommitTimestamp: true},
+				{Name: "Country", SpannerType: "STRING(2)", IsNullable: true},
+				{Name: "Rank", SpannerType: "INT64", Default: "0"},
+				{Name: "Code", SpannerType: "STRING(10)"},
+			}, PrimaryKey: []*spankeys.Column{key("SingerID")}},
+			{Name: "Album", Interleave: &spankeys.Interleave{Table: "Singer", OnDelete: spankeys.OnDeleteCascade}, Columns: []*spankeys.Column{
+				{Name: "SingerID", SpannerType: "INT64"},
+				{Name: "AlbumID", SpannerType: "INT64"},
+				{Name: "Score", SpannerType: "FLOAT64", IsNullable: true},
+			}, PrimaryKey: []*spankeys.Column{key("SingerID"), {Name: "AlbumID", Ordering: spankeys.ColumnOrderingDesc}}},
+			{Name: "Song", Interleave: &spankeys.Interleave{Table: "Singer", OnDelete: spankeys.OnDeleteCascade}, Columns: []*spankeys.Column{
+				{Name: "SingerID", SpannerType: "INT64"},
+				{Name: "SongID", SpannerType: "INT64", IsNullable: true},
+			}, PrimaryKey: []*spankeys.Column{key("SingerID"), key("SongID")}},
+			{Name: "Label", Columns: []*spankeys.Column{
+				{Name: "LabelID", SpannerType: "INT64"},
+			}, PrimaryKey: []*spankeys.Column{key("LabelID")}},
+		},
+		Indexes: []*spankeys.Index{
+			{Name: "SingerByName", Table: "Singer", IsUnique: true, Columns: []*spankeys.IndexColumn{indexColumn("Name", 1)}},
+		},
+		ForeignKeys: []*spankeys.ForeignKey{
+			{Name: "FK_SingerCountry", Table: "Singer", Columns: []string{"Country"}, ReferencedTable: "Label", ReferencedColumns: []string{"LabelID"}},
+			// the unnamed foreign keys are matched by the definition
+			{Name: "FK_Song_Singer_1", IsUnnamed: true, Table: "Song", Columns: []string{"SingerID"}, ReferencedTable: "Singer", ReferencedColumns: []string{"SingerID"}},
+			{Name: "FK_Song_Label_1", IsUnnamed: true, Table: "Song", Columns: []string{"SongID"}, ReferencedTable: "Label", ReferencedColumns: []string{"LabelID"}},
+		},
+	}
+
+	d := spankeys.DiffSchemas(from, to)
+	assert.Equal(t, []string{
+		"DROP INDEX SingerByName",
+		"DROP INDEX SingerByOld",
+		"DROP TABLE Legacy",
+		"CREATE TABLE Label (\n  LabelID INT64 NOT NULL,\n) PRIMARY KEY (LabelID)",
+		"ALTER TABLE Singer DROP COLUMN Old",
+		"ALTER TABLE Singer ALTER COLUMN Name STRING(MAX) NOT NULL",
+		"ALTER TABLE Singer ALTER COLUMN UpdatedAt SET OPTIONS (allow_commit_timestamp=true)",
+		"ALTER TABLE Singer ADD COLUMN Country STRING(2)",
+		"ALTER TABLE Singer ADD COLUMN Rank INT64 NOT NULL DEFAULT (0)",
+		"ALTER TABLE Song SET ON DELETE CASCADE",
+		"CREATE UNIQUE INDEX SingerByName ON Singer (Name)",
+		"ALTER TABLE Singer ADD CONSTRAINT FK_SingerCountry FOREIGN KEY (Country) REFERENCES Label (LabelID)",
+		"ALTER TABLE Song ADD FOREIGN KEY (SongID) REFERENCES Label (LabelID)",
+	}, d.Statements)
+
+	var unsupported []string
+	for _, c := range d.Unsupported {
+		unsupported = append(unsupported, c.String())
+	}
+	assert.Equal(t, []string{
+		"table Singer: unnamed foreign key referencing Legacy cannot be dropped without the name in the database",
+		"table Singer column Code: NOT NULL column without DEFAULT cannot be added to an existing table; add it as nullable, backfill it and then set NOT NULL",
+		// the other changes of the table to be recreated are not generated
+		"table Album: primary key cannot be changed",
+		"table Song column SongID: nullability of a primary key column cannot be changed",
+	}, unsupported)
+	assert.True(t, d.HasChanges())
+
+	assert.False(t, spankeys.DiffSchemas(to, to).HasChanges())
+}
@@ -0,0 +1,302 @@
+package spankeys
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// TypeCode is the base type of a SpannerType.
+type TypeCode string
+
+const (
+	TypeBool      TypeCode = "BOOL"
+	TypeInt64     TypeCode = "INT64"
+	TypeFloat64   TypeCode = "FLOAT64"
+	TypeNumeric   TypeCode = "NUMERIC"
+	TypeString    TypeCode = "STRING"
+	TypeJSON      TypeCode = "JSON"
+	TypeBytes     TypeCode = "BYTES"
+	TypeDate      TypeCode = "DATE"
+	TypeTimestamp TypeCode = "TIMESTAMP"
+	TypeArray     TypeCode = "ARRAY"
+	TypeStruct    TypeCode = "STRUCT"
+)
+
+// SpannerType is a parsed SPANNER_TYPE (e.g. STRING(MAX), ARRAY<INT64>, STRUCT<Name STRING(MAX)>).
+type SpannerType struct {
+	Code TypeCode
+
+	// Length is the max length of STRING or BYTES. It is zero if IsMax.
+	Length int64
+	IsMax  bool
+
+	// ElementType is the type of the elements of ARRAY.
+	ElementType *SpannerType
+
+	// Fields are the fields of STRUCT.
+	Fields []*StructField
+}
+
+// StructField is a field of STRUCT. Name is empty for an unnamed field.
+type StructField struct {
+	Name string
+	Type *SpannerType
+}
+
+// ParseSpannerType parses the SPANNER_TYPE.
+func ParseSpannerType(s string) (*SpannerType, error) {
+	p := &typeParser{s: s}
+	t, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("invalid Spanner type: %s", s)
+	}
+	return t, nil
+}
+
+func (t *SpannerType) String() string {
+	switch t.Code {
+	case TypeArray:
+		return fmt.Sprintf("ARRAY<%s>", t.ElementType)
+	case TypeStruct:
+		fields := make([]string, len(t.Fields))
+		for i, f := range t.Fields {
+			if f.Name == "" {
+				fields[i] = f.Type.String()
+			} else {
+				fields[i] = fmt.Sprintf("%s %s", f.Name, f.Type)
+			}
+		}
+		return fmt.Sprintf("STRUCT<%s>", strings.Join(fields, ", "))
+	case TypeString, TypeBytes:
+		if t.IsMax {
+			return fmt.Sprintf("%s(MAX)", t.Code)
+		}
+		return fmt.Sprintf("%s(%d)", t.Code, t.Length)
+	}
+	return string(t.Code)
+}
+
+var typeCodeToProto = map[TypeCode]sppb.TypeCode{
+	TypeBool:      sppb.TypeCode_BOOL,
+	TypeInt64:     sppb.TypeCode_INT64,
+	TypeFloat64:   sppb.TypeCode_FLOAT64,
+	TypeNumeric:   sppb.TypeCode_NUMERIC,
+	TypeString:    sppb.TypeCode_STRING,
+	TypeJSON:      sppb.TypeCode_JSON,
+	TypeBytes:     sppb.TypeCode_BYTES,
+	TypeDate:      sppb.TypeCode_DATE,
+	TypeTimestamp: sppb.TypeCode_TIMESTAMP,
+	TypeArray:     sppb.TypeCode_ARRAY,
+	TypeStruct:    sppb.TypeCode_STRUCT,
+}
+
+// ToProto converts the type to sppb.Type.
+func (t *SpannerType) ToProto() (*sppb.Type, error) {
+	code, ok := typeCodeToProto[t.Code]
+	if !ok {
+		return nil, fmt.Errorf("type %s cannot be converted to sppb.Type", t.Code)
+	}
+	pt := &sppb.Type{Code: code}
+	switch t.Code {
+	case TypeArray:
+		elem, err := t.ElementType.ToProto()
+		if err != nil {
+			return nil, err
+		}
+		pt.ArrayElementType = elem
+	case TypeStruct:
+		st := &sppb.StructType{}
+		for _, f := range t.Fields {
+			ft, err := f.Type.ToProto()
+			if err != nil {
+				return nil, err
+			}
+			st.Fields = append(st.Fields, &sppb.StructType_Field{Name: f.Name, Type: ft})
+		}
+		pt.StructType = st
+	}
+	return pt, nil
+}
+
+// SpannerTypeFromProto converts sppb.Type to SpannerType.
+// STRING and BYTES are MAX because sppb.Type does not have the length.
+func SpannerTypeFromProto(pt *sppb.Type) (*SpannerType, error) {
+	if pt == nil {
+		return nil, fmt.Errorf("type is nil")
+	}
+	for code, c := range typeCodeToProto {
+		if c != pt.Code {
+			continue
+		}
+		t := &SpannerType{Code: code}
+		switch code {
+		case TypeString, TypeBytes:
+			t.IsMax = true
+		case TypeArray:
+			elem, err := SpannerTypeFromProto(pt.ArrayElementType)
+			if err != nil {
+				return nil, err
+			}
+			t.ElementType = elem
+		case TypeStruct:
+			for _, f := range pt.GetStructType().GetFields() {
+				ft, err := SpannerTypeFromProto(f.Type)
+				if err != nil {
+					return nil, err
+				}
+				t.Fields = append(t.Fields, &StructField{Name: f.Name, Type: ft})
+			}
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("unsupported type code: %s", pt.Code)
+}
+
+// typeParser is a recursive descent parser of SPANNER_TYPE.
+type typeParser struct {
+	s   string
+	pos int
+}
+
+func (p *typeParser) parseType() (*SpannerType, error) {
+	p.skipSpaces()
+	name := strings.ToUpper(p.word())
+	if name == "" {
+		return nil, p.errorf("type name expected")
+	}
+	return p.parseTypeOf(TypeCode(name))
+}
+
+// parseTypeOf parses the rest of the type after the type name.
+func (p *typeParser) parseTypeOf(code TypeCode) (*SpannerType, error) {
+	switch code {
+	case TypeBool, TypeInt64, TypeFloat64, TypeNumeric, TypeJSON, TypeDate, TypeTimestamp:
+		return &SpannerType{Code: code}, nil
+	case TypeString, TypeBytes:
+		if !p.consume('(') {
+			return nil, p.errorf("length of %s expected", code)
+		}
+		p.skipSpaces()
+		length := strings.ToUpper(p.word())
+		if !p.consume(')') {
+			return nil, p.errorf("')' expected")
+		}
+		if length == "MAX" {
+			return &SpannerType{Code: code, IsMax: true}, nil
+		}
+		n, err := strconv.ParseInt(length, 10, 64)
+		if err != nil || n < 1 {
+			return nil, p.errorf("invalid length of %s: %s", code, length)
+		}
+		return &SpannerType{Code: code, Length: n}, nil
+	case TypeArray:
+		if !p.consume('<') {
+			return nil, p.errorf("'<' expected")
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if elem.Code == TypeArray {
+			return nil, p.errorf("nested ARRAY type is not supported")
+		}
+		if !p.consume('>') {
+			return nil, p.errorf("'>' expected")
+		}
+		return &SpannerType{Code: TypeArray, ElementType: elem}, nil
+	case TypeStruct:
+		if !p.consume('<') {
+			return nil, p.errorf("'<' expected")
+		}
+		t := &SpannerType{Code: TypeStruct}
+		if p.consume('>') {
+			return t, nil
+		}
+		for {
+			f, err := p.parseField()
+			if err != nil {
+				return nil, err
+			}
+			t.Fields = append(t.Fields, f)
+			if p.consume('>') {
+				return t, nil
+			}
+			if !p.consume(',') {
+				return nil, p.errorf("',' or '>' expected")
+			}
+		}
+	}
+	return nil, p.errorf("unknown type: %s", code)
+}
+
+// parseField parses a STRUCT field, which is "name type" or "type".
+func (p *typeParser) parseField() (*StructField, error) {
+	p.skipSpaces()
+	w := p.word()
+	if w == "" {
+		return nil, p.errorf("field expected")
+	}
+	p.skipSpaces()
+	if p.pos < len(p.s) && strings.IndexByte(",<>(", p.s[p.pos]) < 0 {
+		t, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &StructField{Name: w, Type: t}, nil
+	}
+	t, err := p.parseTypeOf(TypeCode(strings.ToUpper(w)))
+	if err != nil {
+		return nil, err
+	}
+	return &StructField{Type: t}, nil
+}
+
+func (p *typeParser) word() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if !(c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *typeParser) consume(c byte) bool {
+	p.skipSpaces()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *typeParser) skipSpaces() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *typeParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid Spanner type %q at %d: %s", p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+// parsedColumnType returns Type of the column, or parses SpannerType if Type is not set.
+func parsedColumnType(col *Column) *SpannerType {
+	if col.Type != nil {
+		return col.Type
+	}
+	t, err := ParseSpannerType(col.SpannerType)
+	if err != nil {
+		return nil
+	}
+	return t
+}
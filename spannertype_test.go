@@ -0,0 +1,111 @@
+package spankeys_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+
+	"github.com/castaneai/spankeys"
+)
+
+func TestParseSpannerType(t *testing.T) {
+	cases := []struct {
+		s        string
+		expected *spankeys.SpannerType
+	}{
+		{"BOOL", &spankeys.SpannerType{Code: spankeys.TypeBool}},
+		{"INT64", &spankeys.SpannerType{Code: spankeys.TypeInt64}},
+		{"FLOAT64", &spankeys.SpannerType{Code: spankeys.TypeFloat64}},
+		{"DATE", &spankeys.SpannerType{Code: spankeys.TypeDate}},
+		{"TIMESTAMP", &spankeys.SpannerType{Code: spankeys.TypeTimestamp}},
+		{"STRING(MAX)", &spankeys.SpannerType{Code: spankeys.TypeString, IsMax: true}},
+		{"STRING(36)", &spankeys.SpannerType{Code: spankeys.TypeString, Length: 36}},
+		{"BYTES(1024)", &spankeys.SpannerType{Code: spankeys.TypeBytes, Length: 1024}},
+		{"ARRAY<INT64>", &spankeys.SpannerType{Code: spankeys.TypeArray, ElementType: &spankeys.SpannerType{Code: spankeys.TypeInt64}}},
+		{"ARRAY<BYTES(MAX)>", &spankeys.SpannerType{Code: spankeys.TypeArray, ElementType: &spankeys.SpannerType{Code: spankeys.TypeBytes, IsMax: true}}},
+		{"NUMERIC", &spankeys.SpannerType{Code: spankeys.TypeNumeric}},
+		{"JSON", &spankeys.SpannerType{Code: spankeys.TypeJSON}},
+		{"STRUCT<>", &spankeys.SpannerType{Code: spankeys.TypeStruct}},
+		{"STRUCT<Name STRING(MAX), Scores ARRAY<FLOAT64>>", &spankeys.SpannerType{Code: spankeys.TypeStruct, Fields: []*spankeys.StructField{
+			{Name: "Name", Type: &spankeys.SpannerType{Code: spankeys.TypeString, IsMax: true}},
+			{Name: "Scores", Type: &spankeys.SpannerType{Code: spankeys.TypeArray, ElementType: &spankeys.SpannerType{Code: spankeys.TypeFloat64}}},
+		}}},
+		{"ARRAY<STRUCT<INT64, x STRUCT<y DATE>>>", &spankeys.SpannerType{Code: spankeys.TypeArray, ElementType: &spankeys.SpannerType{Code: spankeys.TypeStruct, Fields: []*spankeys.StructField{
+			{Type: &spankeys.SpannerType{Code: spankeys.TypeInt64}},
+			{Name: "x", Type: &spankeys.SpannerType{Code: spankeys.TypeStruct, Fields: []*spankeys.StructField{
+				{Name: "y", Type: &spankeys.SpannerType{Code: spankeys.TypeDate}},
+			}}},
+		}}}},
+	}
+	for _, c := range cases {
+		typ, err := spankeys.ParseSpannerType(c.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, c.expected, typ)
+		assert.Equal(t, c.s, typ.String())
+	}
+
+	for _, s := range []string{"", "STRING", "INT64(10)", "STRING(0)", "BYTES(abc)", "ARRAY<ARRAY<INT64>>", "UNKNOWN", "STRUCT<a INT64", "ARRAY<INT64>>"} {
+		_, err := spankeys.ParseSpannerType(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestSpannerTypeProto(t *testing.T) {
+	typ, err := spankeys.ParseSpannerType("ARRAY<STRUCT<Name STRING(36), Data BYTES(MAX)>>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := typ.ToProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &sppb.Type{
+		Code: sppb.TypeCode_ARRAY,
+		ArrayElementType: &sppb.Type{
+			Code: sppb.TypeCode_STRUCT,
+			StructType: &sppb.StructType{Fields: []*sppb.StructType_Field{
+				{Name: "Name", Type: &sppb.Type{Code: sppb.TypeCode_STRING}},
+				{Name: "Data", Type: &sppb.Type{Code: sppb.TypeCode_BYTES}},
+			}},
+		},
+	}, pt)
+
+	back, err := spankeys.SpannerTypeFromProto(pt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the length of STRING is lost in sppb.Type
+	assert.Equal(t, "ARRAY<STRUCT<Name STRING(MAX), Data BYTES(MAX)>>", back.String())
+
+	for _, s := range []string{"NUMERIC", "JSON", "ARRAY<NUMERIC>", "ARRAY<JSON>"} {
+		typ, err := spankeys.ParseSpannerType(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pt, err := typ.ToProto()
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := spankeys.SpannerTypeFromProto(pt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s, back.String())
+	}
+	pt, err = (&spankeys.SpannerType{Code: spankeys.TypeNumeric}).ToProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &sppb.Type{Code: sppb.TypeCode_NUMERIC}, pt)
+	pt, err = (&spankeys.SpannerType{Code: spankeys.TypeJSON}).ToProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &sppb.Type{Code: sppb.TypeCode_JSON}, pt)
+
+	_, err = spankeys.SpannerTypeFromProto(&sppb.Type{Code: sppb.TypeCode_TYPE_CODE_UNSPECIFIED})
+	assert.Error(t, err)
+}
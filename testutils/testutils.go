@@ -46,13 +46,24 @@ func PrepareDatabase(ctx context.Context, ddls []string, opts ...option.ClientOp
 	if err != nil {
 		return err
 	}
-	if emulatorAddr := os.Getenv("SPANNER_EMULATOR_HOST"); emulatorAddr != "" {
-		conn, err := grpc.DialContext(ctx, emulatorAddr, grpc.WithInsecure(), grpc.WithBlock())
-		if err != nil {
-			return err
-		}
-		opts = append(opts, option.WithGRPCConn(conn))
+	return prepareDatabase(ctx, dsn, ddls, opts...)
+}
+
+// PrepareAnotherDatabase prepares a database named SPANNER_DATABASE_ID with the suffix (e.g. for the destination of copying).
+func PrepareAnotherDatabase(ctx context.Context, suffix string, ddls []string, opts ...option.ClientOption) error {
+	dsn, err := makeDSNFromEnv()
+	if err != nil {
+		return err
+	}
+	return prepareDatabase(ctx, dsn+spankeys.DSN("-"+suffix), ddls, opts...)
+}
+
+func prepareDatabase(ctx context.Context, dsn spankeys.DSN, ddls []string, opts ...option.ClientOption) error {
+	emulatorOpts, err := EmulatorClientOptions(ctx)
+	if err != nil {
+		return err
 	}
+	opts = append(opts, emulatorOpts...)
 
 	admin, err := spadmin.NewClient(ctx, dsn.Parent(), opts...)
 	if err != nil {
@@ -96,3 +107,38 @@ func NewSpannerClient(ctx context.Context) (*spanner.Client, error) {
 	}
 	return spanner.NewClient(ctx, string(dsn))
 }
+
+func NewAnotherSpannerClient(ctx context.Context, suffix string) (*spanner.Client, error) {
+	dsn, err := makeDSNFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return spanner.NewClient(ctx, string(dsn)+"-"+suffix)
+}
+
+// EmulatorClientOptions returns the client options to connect to SPANNER_EMULATOR_HOST if it is set.
+func EmulatorClientOptions(ctx context.Context) ([]option.ClientOption, error) {
+	emulatorAddr := os.Getenv("SPANNER_EMULATOR_HOST")
+	if emulatorAddr == "" {
+		return nil, nil
+	}
+	conn, err := grpc.DialContext(ctx, emulatorAddr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return nil, err
+	}
+	return []option.ClientOption{option.WithGRPCConn(conn)}, nil
+}
+
+// AnotherDSN returns the DSN of the database prepared by PrepareAnotherDatabase.
+func AnotherDSN(suffix string) (spankeys.DSN, error) {
+	dsn, err := makeDSNFromEnv()
+	if err != nil {
+		return "", err
+	}
+	return dsn + spankeys.DSN("-"+suffix), nil
+}
+
+// DSN returns the DSN of the database prepared by PrepareDatabase.
+func DSN() (spankeys.DSN, error) {
+	return makeDSNFromEnv()
+}
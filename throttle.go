@@ -0,0 +1,192 @@
+package spankeys
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+const (
+	DefaultTargetCommitLatency = 1 * time.Second
+	DefaultMaxAbortRate        = 0.1
+	minThrottleFactor          = 0.05
+)
+
+type ThrottleOptions struct {
+	// MutationsPerSecond limits mutations of all workers. If zero, mutations are not limited.
+	MutationsPerSecond float64
+
+	// CommitsPerSecond limits commits of all workers. If zero, commits are not limited.
+	CommitsPerSecond float64
+
+	// Adaptive lowers the limits while commit latency or the aborted rate climbs, and recovers them gradually.
+	// If no limit is set, it delays each commit by the average commit latency scaled by the backoff instead.
+	Adaptive bool
+
+	// TargetCommitLatency is the commit latency regarded as overloaded (default: DefaultTargetCommitLatency).
+	TargetCommitLatency time.Duration
+
+	// MaxAbortRate is the rate of aborted transactions regarded as overloaded (default: DefaultMaxAbortRate).
+	MaxAbortRate float64
+}
+
+// Throttle limits the rate of commits of key range workers. It is safe for concurrent use.
+type Throttle struct {
+	opts ThrottleOptions
+
+	mu            sync.Mutex
+	nextMutation  time.Time
+	nextCommit    time.Time
+	factor        float64
+	latencyAvg    float64
+	abortRateAvg  float64
+	observedCount int
+}
+
+func NewThrottle(opts ThrottleOptions) *Throttle {
+	if opts.TargetCommitLatency <= 0 {
+		opts.TargetCommitLatency = DefaultTargetCommitLatency
+	}
+	if opts.MaxAbortRate <= 0 {
+		opts.MaxAbortRate = DefaultMaxAbortRate
+	}
+	return &Throttle{opts: opts, factor: 1}
+}
+
+// Wait blocks until a commit of the mutations is allowed.
+func (t *Throttle) Wait(ctx context.Context, mutations int64) error {
+	t.mu.Lock()
+	now := time.Now()
+	var wait time.Duration
+	if t.opts.CommitsPerSecond > 0 {
+		wait = maxDuration(wait, reserve(&t.nextCommit, now, 1, t.opts.CommitsPerSecond*t.factor))
+	}
+	if t.opts.MutationsPerSecond > 0 {
+		wait = maxDuration(wait, reserve(&t.nextMutation, now, mutations, t.opts.MutationsPerSecond*t.factor))
+	}
+	if t.opts.Adaptive && t.opts.CommitsPerSecond <= 0 && t.opts.MutationsPerSecond <= 0 {
+		// a worker commits at 1/latency per second, so the delay scales its rate by the factor
+		wait = time.Duration((1/t.factor - 1) * t.latencyAvg)
+	}
+	t.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve returns the duration to wait for n tokens, and advances the next available time.
+func reserve(next *time.Time, now time.Time, n int64, rate float64) time.Duration {
+	if next.Before(now) {
+		*next = now
+	}
+	wait := next.Sub(now)
+	*next = next.Add(time.Duration(float64(n) / rate * float64(time.Second)))
+	return wait
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Observe records a commit which took the latency after the attempts of the transaction,
+// of which the aborts were aborted. A zero latency is not recorded, e.g. when all attempts were aborted.
+// In the adaptive mode, it adjusts the limits by additive increase and multiplicative decrease.
+func (t *Throttle) Observe(latency time.Duration, attempts, aborts int) {
+	if !t.opts.Adaptive || attempts < 1 {
+		return
+	}
+	aborted := float64(aborts) / float64(attempts)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// exponentially weighted moving average
+	const alpha = 0.2
+	if t.observedCount == 0 {
+		t.abortRateAvg = aborted
+	} else {
+		t.abortRateAvg = alpha*aborted + (1-alpha)*t.abortRateAvg
+	}
+	if latency > 0 {
+		if t.latencyAvg == 0 {
+			t.latencyAvg = float64(latency)
+		} else {
+			t.latencyAvg = alpha*float64(latency) + (1-alpha)*t.latencyAvg
+		}
+	}
+	t.observedCount++
+
+	if t.latencyAvg > float64(t.opts.TargetCommitLatency) || t.abortRateAvg > t.opts.MaxAbortRate {
+		t.factor /= 2
+		if t.factor < minThrottleFactor {
+			t.factor = minThrottleFactor
+		}
+		return
+	}
+	t.factor += 0.05
+	if t.factor > 1 {
+		t.factor = 1
+	}
+}
+
+// Factor returns the current ratio of the limits to the configured limits.
+func (t *Throttle) Factor() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.factor
+}
+
+type commitThrottleKey struct{}
+
+// commitThrottle passes the commits while processing a key range to the throttle.
+type commitThrottle struct {
+	throttle  *Throttle
+	committed bool
+}
+
+func withCommitThrottle(ctx context.Context, throttle *Throttle) (context.Context, *commitThrottle) {
+	o := &commitThrottle{throttle: throttle}
+	return context.WithValue(ctx, commitThrottleKey{}, o), o
+}
+
+// waitCommit blocks until the throttle of the key range being processed, if any, allows a commit of the mutations.
+func waitCommit(ctx context.Context, mutations int64) error {
+	if o, ok := ctx.Value(commitThrottleKey{}).(*commitThrottle); ok {
+		o.committed = true
+		return o.throttle.Wait(ctx, mutations)
+	}
+	return nil
+}
+
+// observeCommit reports a commit to the throttle of the key range being processed, if any.
+func observeCommit(ctx context.Context, latency time.Duration, attempts, aborts int) {
+	if o, ok := ctx.Value(commitThrottleKey{}).(*commitThrottle); ok {
+		o.throttle.Observe(latency, attempts, aborts)
+	}
+}
+
+// applyMutations waits for the throttle, applies the mutations and reports the commit latency to the throttle.
+func applyMutations(ctx context.Context, client *spanner.Client, ms []*spanner.Mutation, mutations int64) error {
+	if err := waitCommit(ctx, mutations); err != nil {
+		return err
+	}
+	start := time.Now()
+	if _, err := client.Apply(ctx, ms); err != nil {
+		return err
+	}
+	observeCommit(ctx, time.Since(start), 1, 0)
+	return nil
+}
@@ -0,0 +1,106 @@
+package spankeys_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+)
+
+func TestThrottle(t *testing.T) {
+	ctx := context.Background()
+
+	{
+		th := spankeys.NewThrottle(spankeys.ThrottleOptions{CommitsPerSecond: 20})
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			if err := th.Wait(ctx, 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// the first commit does not wait
+		assert.True(t, time.Since(start) >= 4*50*time.Millisecond)
+	}
+
+	{
+		th := spankeys.NewThrottle(spankeys.ThrottleOptions{MutationsPerSecond: 1000})
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := th.Wait(ctx, 100); err != nil {
+				t.Fatal(err)
+			}
+		}
+		assert.True(t, time.Since(start) >= 2*100*time.Millisecond)
+	}
+
+	{
+		th := spankeys.NewThrottle(spankeys.ThrottleOptions{CommitsPerSecond: 1})
+		if err := th.Wait(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+		cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, th.Wait(cctx, 1))
+	}
+}
+
+func TestAdaptiveThrottle(t *testing.T) {
+	th := spankeys.NewThrottle(spankeys.ThrottleOptions{
+		CommitsPerSecond:    100,
+		Adaptive:            true,
+		TargetCommitLatency: 100 * time.Millisecond,
+	})
+	assert.Equal(t, 1.0, th.Factor())
+
+	th.Observe(50*time.Millisecond, 1, 0)
+	assert.Equal(t, 1.0, th.Factor())
+
+	// backs off while commits are slow
+	for i := 0; i < 5; i++ {
+		th.Observe(time.Second, 1, 0)
+	}
+	slow := th.Factor()
+	assert.True(t, slow < 0.1)
+
+	// recovers gradually
+	for i := 0; i < 30; i++ {
+		th.Observe(10*time.Millisecond, 1, 0)
+	}
+	assert.True(t, th.Factor() > slow)
+
+	// backs off while transactions are retried by aborts
+	aborted := spankeys.NewThrottle(spankeys.ThrottleOptions{CommitsPerSecond: 100, Adaptive: true})
+	aborted.Observe(10*time.Millisecond, 3, 2)
+	assert.Equal(t, 0.5, aborted.Factor())
+
+	// a non-adaptive throttle ignores observations
+	fixed := spankeys.NewThrottle(spankeys.ThrottleOptions{CommitsPerSecond: 100})
+	fixed.Observe(time.Minute, 1, 0)
+	assert.Equal(t, 1.0, fixed.Factor())
+}
+
+func TestAdaptiveThrottleWithoutLimits(t *testing.T) {
+	ctx := context.Background()
+	th := spankeys.NewThrottle(spankeys.ThrottleOptions{
+		Adaptive:            true,
+		TargetCommitLatency: 100 * time.Millisecond,
+	})
+	start := time.Now()
+	if err := th.Wait(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+
+	// slow commits halve the factor twice, so a commit waits 3 times the average latency
+	th.Observe(200*time.Millisecond, 1, 0)
+	th.Observe(200*time.Millisecond, 1, 0)
+	assert.Equal(t, 0.25, th.Factor())
+	start = time.Now()
+	if err := th.Wait(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, time.Since(start) >= 600*time.Millisecond)
+}
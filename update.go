@@ -0,0 +1,194 @@
+package spankeys
+
+import (
+	"context"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+)
+
+// UpdateFunc returns new values of the columns for the row, or nil to skip the row.
+// It is called in a read-write transaction, and can be called again for the same row when the transaction is retried
+// or the row is deferred to the next transaction by the commit size limit.
+type UpdateFunc func(r *spanner.Row) ([]interface{}, error)
+
+type UpdateOptions struct {
+	// Concurrency is the number of key ranges updated at the same time (default: 1).
+	Concurrency int
+
+	// BatchSize is the max row count updated by one commit (default: result of EstimateBatchSize for the columns).
+	BatchSize int
+
+	// PageSize is the row count fetched by one query while partitioning (default: DefaultPageSize).
+	PageSize int
+
+	// MaxRetries is the max retry count of a transaction aborted by Cloud Spanner (default: DefaultMaxRetries).
+	MaxRetries int
+
+	// Checkpoint makes the update resumable. See RunOptions.
+	Checkpoint CheckpointStore
+
+	// Throttle limits the rate of the update. See RunOptions.
+	Throttle *Throttle
+
+	// Observer receives the progress of the update. See RunOptions.
+	Observer Observer
+}
+
+type UpdateReport struct {
+	UpdatedRanges   []*CountableKeyRange
+	UpdatedRowCount int64
+	SkippedRowCount int64
+}
+
+// UpdateRows reads the columns of all rows range by range, and updates them by the values returned by fn.
+// The row passed to fn has the primary key columns and the columns.
+func UpdateRows(ctx context.Context, client *spanner.Client, tableName string, columns []string, fn UpdateFunc, opts *UpdateOptions) (*UpdateReport, error) {
+	if opts == nil {
+		opts = &UpdateOptions{}
+	}
+	pkCols, err := GetPrimaryKeyColumns(ctx, client, tableName)
+	if err != nil {
+		return nil, err
+	}
+	batchSize := opts.BatchSize
+	if batchSize < 1 {
+		bs, err := calcUpdateBatchSize(ctx, client, tableName, pkCols, columns)
+		if err != nil {
+			return nil, err
+		}
+		batchSize = bs
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries < 1 {
+		maxRetries = DefaultMaxRetries
+	}
+
+	var readColumns []string
+	for _, col := range pkCols {
+		readColumns = append(readColumns, col.Name)
+	}
+	readColumns = append(readColumns, columns...)
+
+	var (
+		mu      sync.Mutex
+		updated int64
+		skipped int64
+	)
+	it := NewKeyRangeIterator(ctx, client, tableName, pkCols, &KeyRangeOptions{
+		MutationBatchSize: batchSize,
+		PageSize:          opts.PageSize,
+	})
+	run, err := RunKeyRanges(ctx, it, func(ctx context.Context, r *CountableKeyRange) error {
+		u, s, err := updateKeyRange(ctx, client, tableName, pkCols, readColumns, r.KeyRange, fn, batchSize, maxRetries)
+		mu.Lock()
+		updated += u
+		skipped += s
+		mu.Unlock()
+		return err
+	}, &RunOptions{
+		Concurrency: opts.Concurrency,
+		Checkpoint:  opts.Checkpoint,
+		Throttle:    opts.Throttle,
+		Observer:    opts.Observer,
+	})
+	if run == nil {
+		return nil, err
+	}
+	return &UpdateReport{UpdatedRanges: run.Completed, UpdatedRowCount: updated, SkippedRowCount: skipped}, err
+}
+
+// updateKeyRange updates the rows in the key range by a transaction.
+// If the values exceed the limits of a commit, the rest of the key range is updated by the next transaction.
+func updateKeyRange(ctx context.Context, client *spanner.Client, tableName string, pkCols []*Column, readColumns []string, kr spanner.KeyRange, fn UpdateFunc, batchSize, maxRetries int) (int64, int64, error) {
+	var updated, skipped int64
+	for {
+		var u, s int64
+		var rest *spanner.KeyRange
+		if err := runTransactionWithRetry(ctx, client, maxRetries, func(ctx context.Context, tx *spanner.ReadWriteTransaction) (int64, error) {
+			u, s, rest = 0, 0, nil
+			batch := &mutationBatch{maxRows: batchSize}
+			iter := tx.Read(ctx, tableName, kr, readColumns)
+			defer iter.Stop()
+			for {
+				row, err := iter.Next()
+				if err == iterator.Done {
+					break
+				}
+				if err != nil {
+					return 0, err
+				}
+				values, err := fn(row)
+				if err != nil {
+					return 0, err
+				}
+				if values == nil {
+					s++
+					continue
+				}
+				key, err := decodeKey(row, pkCols)
+				if err != nil {
+					return 0, err
+				}
+				values = append(append([]interface{}{}, key...), values...)
+				size := valuesSize(values)
+				if !batch.fits(size) {
+					rest = &spanner.KeyRange{Start: key, End: kr.End, Kind: spanner.ClosedOpen}
+					if kr.Kind == spanner.ClosedClosed || kr.Kind == spanner.OpenClosed {
+						rest.Kind = spanner.ClosedClosed
+					}
+					break
+				}
+				batch.add(spanner.Update(tableName, readColumns, values), size, len(readColumns))
+				u++
+			}
+			return batch.mutationCount, tx.BufferWrite(batch.mutations)
+		}); err != nil {
+			return updated, skipped, err
+		}
+		updated += u
+		skipped += s
+		if rest == nil {
+			return updated, skipped, nil
+		}
+		kr = *rest
+	}
+}
+
+// calcUpdateBatchSize estimates the batch size by only the updated columns and the primary key columns.
+func calcUpdateBatchSize(ctx context.Context, client *spanner.Client, tableName string, pkCols []*Column, columns []string) (int, error) {
+	allCols, err := GetColumns(ctx, client, tableName)
+	if err != nil {
+		return 0, err
+	}
+	idxes, err := GetSecondaryIndexes(ctx, client, tableName)
+	if err != nil {
+		return 0, err
+	}
+	var cols []*Column
+	for _, col := range allCols {
+		if containsColumn(pkCols, col.Name) || containsString(columns, col.Name) {
+			cols = append(cols, col)
+		}
+	}
+	return EstimateBatchSize(MutationUpdate, cols, pkCols, len(idxes)), nil
+}
+
+func containsColumn(cols []*Column, name string) bool {
+	for _, col := range cols {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
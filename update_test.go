@@ -0,0 +1,70 @@
+package spankeys_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/castaneai/spankeys"
+	"github.com/castaneai/spankeys/testutils"
+)
+
+func TestUpdateRows(t *testing.T) {
+	tableName := "UpdateRowsTest"
+
+	ctx := context.Background()
+	if err := testutils.PrepareDatabase(ctx, []string{fmt.Sprintf(`
+CREATE TABLE %s (
+    ID INT64 NOT NULL,
+    Name STRING(255) NOT NULL,
+    UpperName STRING(255),
+) PRIMARY KEY (ID)
+`, tableName)}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := testutils.NewSpannerClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ms []*spanner.Mutation
+	for i := 0; i < 1000; i++ {
+		ms = append(ms, spanner.Insert(tableName, []string{"ID", "Name"}, []interface{}{int64(i), fmt.Sprintf("name%d", i)}))
+	}
+	if _, err := c.Apply(ctx, ms); err != nil {
+		t.Fatal(err)
+	}
+
+	// backfill UpperName except for the rows of odd IDs
+	report, err := spankeys.UpdateRows(ctx, c, tableName, []string{"Name", "UpperName"}, func(r *spanner.Row) ([]interface{}, error) {
+		var id int64
+		var name string
+		if err := r.ColumnByName("ID", &id); err != nil {
+			return nil, err
+		}
+		if err := r.ColumnByName("Name", &name); err != nil {
+			return nil, err
+		}
+		if id%2 == 1 {
+			return nil, nil
+		}
+		return []interface{}{name, strings.ToUpper(name)}, nil
+	}, &spankeys.UpdateOptions{Concurrency: 2, BatchSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 10, len(report.UpdatedRanges))
+	assert.Equal(t, int64(500), report.UpdatedRowCount)
+	assert.Equal(t, int64(500), report.SkippedRowCount)
+
+	cnt, err := testutils.CountsRow(ctx, fmt.Sprintf("select count(*) from %s where UpperName = UPPER(Name)", tableName), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(500), cnt)
+}
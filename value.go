@@ -2,6 +2,7 @@ package spankeys
 
 import (
 	"fmt"
+	"math/big"
 	"reflect"
 	"time"
 
@@ -9,14 +10,17 @@ import (
 
 	"cloud.google.com/go/spanner"
 	proto3 "github.com/golang/protobuf/ptypes/struct"
-	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 )
 
 func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 	_, isNull := gcv.Value.Kind.(*proto3.Value_NullValue)
+	typ, err := SpannerTypeFromProto(gcv.Type)
+	if err != nil {
+		return fmt.Errorf("failed to decode GenericColumnValue: %v", err)
+	}
 
-	switch gcv.Type.Code {
-	case sppb.TypeCode_BOOL:
+	switch typ.Code {
+	case TypeBool:
 		if isNull {
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(spanner.NullBool{}))
 			return nil
@@ -27,7 +31,7 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 		}
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_INT64:
+	case TypeInt64:
 		if isNull {
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(spanner.NullInt64{}))
 			return nil
@@ -38,7 +42,7 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 		}
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_FLOAT64:
+	case TypeFloat64:
 		if isNull {
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(spanner.NullFloat64{}))
 			return nil
@@ -49,7 +53,18 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 		}
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_STRING:
+	case TypeNumeric:
+		if isNull {
+			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(spanner.NullNumeric{}))
+			return nil
+		}
+		var v big.Rat
+		if err := gcv.Decode(&v); err != nil {
+			return err
+		}
+		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
+		return nil
+	case TypeString:
 		if isNull {
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(spanner.NullString{}))
 			return nil
@@ -60,7 +75,15 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 		}
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_DATE:
+	case TypeJSON:
+		if isNull {
+			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(spanner.NullJSON{}))
+			return nil
+		}
+		// JSON is decoded to its text as is, so that numbers and the order of keys are preserved
+		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(gcv.Value.GetStringValue()))
+		return nil
+	case TypeDate:
 		if isNull {
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(spanner.NullDate{}))
 			return nil
@@ -71,7 +94,7 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 		}
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_TIMESTAMP:
+	case TypeTimestamp:
 		if isNull {
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(spanner.NullTime{}))
 			return nil
@@ -82,16 +105,16 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 		}
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_BYTES:
+	case TypeBytes:
 		var v []byte
 		if err := gcv.Decode(&v); err != nil {
 			return err
 		}
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_ARRAY:
+	case TypeArray:
 		if isNull {
-			return handleNullArray(gcv.Type.ArrayElementType.Code, ptr)
+			return handleNullArray(typ.ElementType.Code, ptr)
 		}
 		lv, err := getListValue(gcv.Value)
 		if err != nil {
@@ -99,8 +122,8 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 		}
 		cnull := containsNull(lv)
 
-		switch gcv.Type.ArrayElementType.Code {
-		case sppb.TypeCode_BOOL:
+		switch typ.ElementType.Code {
+		case TypeBool:
 			if cnull {
 				v := make([]spanner.NullBool, len(lv.Values))
 				if err := gcv.Decode(&v); err != nil {
@@ -115,7 +138,7 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 			}
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 			return nil
-		case sppb.TypeCode_INT64:
+		case TypeInt64:
 			if cnull {
 				v := make([]spanner.NullInt64, len(lv.Values))
 				if err := gcv.Decode(&v); err != nil {
@@ -130,7 +153,7 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 			}
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 			return nil
-		case sppb.TypeCode_FLOAT64:
+		case TypeFloat64:
 			if cnull {
 				v := make([]spanner.NullFloat64, len(lv.Values))
 				if err := gcv.Decode(&v); err != nil {
@@ -145,7 +168,22 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 			}
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 			return nil
-		case sppb.TypeCode_STRING:
+		case TypeNumeric:
+			if cnull {
+				v := make([]spanner.NullNumeric, len(lv.Values))
+				if err := gcv.Decode(&v); err != nil {
+					return err
+				}
+				reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
+				return nil
+			}
+			v := make([]big.Rat, len(lv.Values))
+			if err := gcv.Decode(&v); err != nil {
+				return err
+			}
+			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
+			return nil
+		case TypeString:
 			if cnull {
 				v := make([]spanner.NullString, len(lv.Values))
 				if err := gcv.Decode(&v); err != nil {
@@ -160,7 +198,24 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 			}
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 			return nil
-		case sppb.TypeCode_DATE:
+		case TypeJSON:
+			if cnull {
+				v := make([]spanner.NullString, len(lv.Values))
+				for i, e := range lv.Values {
+					if _, isNull := e.Kind.(*proto3.Value_NullValue); !isNull {
+						v[i] = spanner.NullString{StringVal: e.GetStringValue(), Valid: true}
+					}
+				}
+				reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
+				return nil
+			}
+			v := make([]string, len(lv.Values))
+			for i, e := range lv.Values {
+				v[i] = e.GetStringValue()
+			}
+			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
+			return nil
+		case TypeDate:
 			if cnull {
 				v := make([]spanner.NullDate, len(lv.Values))
 				if err := gcv.Decode(&v); err != nil {
@@ -175,7 +230,7 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 			}
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 			return nil
-		case sppb.TypeCode_TIMESTAMP:
+		case TypeTimestamp:
 			if cnull {
 				v := make([]spanner.NullTime, len(lv.Values))
 				if err := gcv.Decode(&v); err != nil {
@@ -190,55 +245,66 @@ func DecodeToInterface(gcv *spanner.GenericColumnValue, ptr interface{}) error {
 			}
 			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 			return nil
-		case sppb.TypeCode_ARRAY:
+		case TypeBytes:
+			v := make([][]byte, len(lv.Values))
+			if err := gcv.Decode(&v); err != nil {
+				return err
+			}
+			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
+			return nil
+		case TypeArray:
 			return fmt.Errorf("nested ARRAY type is not supported")
-		case sppb.TypeCode_STRUCT:
+		case TypeStruct:
 			return fmt.Errorf("STRUCT type in ARRAY is not supported")
 		default:
-			return fmt.Errorf("failed to decode GenericColumnValue(typeCode: %s, elementType: %s)", gcv.Type.Code, gcv.Type.ArrayElementType.Code)
+			return fmt.Errorf("failed to decode GenericColumnValue(type: %s)", typ)
 		}
-	case sppb.TypeCode_STRUCT:
+	case TypeStruct:
 		return fmt.Errorf("STRUCT type is not supported")
 	}
-	return fmt.Errorf("failed to decode GenericColumnValue(typeCode: %s)", gcv.Type.Code)
+	return fmt.Errorf("failed to decode GenericColumnValue(type: %s)", typ)
 }
 
-func handleNullArray(elemCode sppb.TypeCode, ptr interface{}) error {
+func handleNullArray(elemCode TypeCode, ptr interface{}) error {
 	switch elemCode {
-	case sppb.TypeCode_BOOL:
+	case TypeBool:
 		var v []bool
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_INT64:
+	case TypeInt64:
 		var v []int64
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_FLOAT64:
+	case TypeFloat64:
 		var v []float64
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_TIMESTAMP:
+	case TypeNumeric:
+		var v []big.Rat
+		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
+		return nil
+	case TypeTimestamp:
 		var v []time.Time
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_DATE:
+	case TypeDate:
 		var v []civil.Date
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_STRING:
+	case TypeString, TypeJSON:
 		var v []string
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_BYTES:
+	case TypeBytes:
 		var v [][]byte
 		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(v))
 		return nil
-	case sppb.TypeCode_ARRAY:
+	case TypeArray:
 		return fmt.Errorf("nested ARRAY type is not supported")
-	case sppb.TypeCode_STRUCT:
+	case TypeStruct:
 		return fmt.Errorf("STRUCT type in ARRAY is not supported")
 	}
-	return fmt.Errorf("failed to decode NULL array element (unknown typecode: %d)", elemCode)
+	return fmt.Errorf("failed to decode NULL array element (unknown type: %s)", elemCode)
 }
 
 func getListValue(v *proto3.Value) (*proto3.ListValue, error) {
@@ -257,3 +323,30 @@ func containsNull(list *proto3.ListValue) bool {
 	}
 	return false
 }
+
+// isNullValue reports whether v is a NULL value decoded by DecodeToInterface.
+func isNullValue(v interface{}) bool {
+	switch vv := v.(type) {
+	case nil:
+		return true
+	case spanner.NullBool:
+		return !vv.Valid
+	case spanner.NullInt64:
+		return !vv.Valid
+	case spanner.NullFloat64:
+		return !vv.Valid
+	case spanner.NullNumeric:
+		return !vv.Valid
+	case spanner.NullString:
+		return !vv.Valid
+	case spanner.NullJSON:
+		return !vv.Valid
+	case spanner.NullDate:
+		return !vv.Valid
+	case spanner.NullTime:
+		return !vv.Valid
+	case []byte:
+		return vv == nil
+	}
+	return false
+}
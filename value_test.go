@@ -2,6 +2,7 @@ package spankeys_test
 
 import (
 	"context"
+	"math/big"
 	"testing"
 	"time"
 
@@ -78,6 +79,33 @@ func TestDecodeToInterface(t *testing.T) {
 		assert.Equal(t, "Hello", v.(string))
 	}
 
+	// numeric
+	{
+		var gcv spanner.GenericColumnValue
+		if err := testutils.SelectOne(ctx, `select NUMERIC "123.456"`, c, &gcv); err != nil {
+			t.Fatal(err)
+		}
+		var v interface{}
+		if err := spankeys.DecodeToInterface(&gcv, &v); err != nil {
+			t.Fatal(err)
+		}
+		n := v.(big.Rat)
+		assert.Equal(t, 0, n.Cmp(big.NewRat(123456, 1000)))
+	}
+
+	// json
+	{
+		var gcv spanner.GenericColumnValue
+		if err := testutils.SelectOne(ctx, `select JSON '{"b":1,"a":[true]}'`, c, &gcv); err != nil {
+			t.Fatal(err)
+		}
+		var v interface{}
+		if err := spankeys.DecodeToInterface(&gcv, &v); err != nil {
+			t.Fatal(err)
+		}
+		assert.JSONEq(t, `{"a":[true],"b":1}`, v.(string))
+	}
+
 	// date
 	{
 		var gcv spanner.GenericColumnValue